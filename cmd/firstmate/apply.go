@@ -0,0 +1,222 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	models "github.com/elsgaard/firstmate/internal"
+	"github.com/elsgaard/firstmate/internal/servercomponents"
+	"github.com/elsgaard/firstmate/internal/servercomponents/executor"
+)
+
+// outcome is the result of applying one component to one host.
+type outcome struct {
+	host     string
+	app      string
+	status   string // ok, changed, failed or skipped
+	version  string // deployed release or commit, when known
+	duration time.Duration
+	err      error
+}
+
+// operation applies one mode (install, update, ...) to a component.
+type operation struct {
+	mode  string
+	steps func(servercomponents.Component, models.Server) []executor.Step
+
+	// healthTimeout bounds the wait for a component to become healthy
+	// after it was applied; 0 skips the health check.
+	healthTimeout time.Duration
+}
+
+// newOperation returns the operation for mode. purge only affects uninstall.
+func newOperation(mode string, purge bool) operation {
+	op := operation{mode: mode}
+	switch mode {
+	case "install":
+		op.steps = servercomponents.Component.InstallSteps
+	case "update":
+		op.steps = servercomponents.Component.UpdateSteps
+	case "uninstall":
+		op.steps = func(c servercomponents.Component, s models.Server) []executor.Step {
+			return c.UninstallSteps(s, purge)
+		}
+	case "rollback":
+		op.steps = servercomponents.Component.RollbackSteps
+	}
+	return op
+}
+
+// applyAll runs op against every target, processing at most forks hosts
+// at a time. Outcomes are returned in target order.
+func applyAll(targets []target, op operation, forks int) []outcome {
+	return parallel(targets, forks, func(t target) []outcome {
+		return applyHost(t, op)
+	})
+}
+
+// applyBatch applies op to one batch of a rolling update; tests replace it.
+var applyBatch = applyAll
+
+// applyRolling runs op on serial hosts at a time, batch after batch, so a
+// component is never down everywhere at once. A host fails if any of its
+// components fails to apply or become healthy. Once more than maxFailPct
+// percent of all hosts have failed, the remaining batches are skipped.
+func applyRolling(targets []target, op operation, serial, forks, maxFailPct int) []outcome {
+	var outs []outcome
+	failed, halted := 0, false
+	for start := 0; start < len(targets); start += serial {
+		batch := targets[start:min(start+serial, len(targets))]
+		if halted {
+			for _, t := range batch {
+				for _, app := range t.apps {
+					outs = append(outs, outcome{host: t.server.FQDN, app: app, status: "skipped"})
+				}
+			}
+			continue
+		}
+
+		res := applyBatch(batch, op, forks)
+		outs = append(outs, res...)
+
+		failedHosts := map[string]bool{}
+		for _, o := range res {
+			if o.status == "failed" {
+				failedHosts[o.host] = true
+			}
+		}
+		failed += len(failedHosts)
+
+		if failed*100 > maxFailPct*len(targets) {
+			fmt.Printf("🛑 Halting rollout: %d of %d hosts failed (max %d%%)\n", failed, len(targets), maxFailPct)
+			halted = true
+		}
+	}
+	return outs
+}
+
+// parallel calls fn for every target, at most forks at a time, and
+// concatenates the results in target order.
+func parallel[T any](targets []target, forks int, fn func(target) []T) []T {
+	results := make([][]T, len(targets))
+	sem := make(chan struct{}, max(forks, 1))
+
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i] = fn(t)
+		}()
+	}
+	wg.Wait()
+
+	return slices.Concat(results...)
+}
+
+// applyHost applies the target's components in order over one SSH
+// connection. Once one fails, the remaining components are skipped since
+// they may depend on it. The host's architecture is detected once, when the
+// first component needs it, unless the inventory sets it.
+func applyHost(t target, op operation) []outcome {
+	logger := executor.Logger(t.server)
+	session := executor.NewSession(t.server)
+	defer session.Close()
+
+	var outs []outcome
+	failed := false
+	for _, app := range t.apps {
+		o := outcome{host: t.server.FQDN, app: app, status: "skipped"}
+		if failed {
+			outs = append(outs, o)
+			continue
+		}
+
+		component := servercomponents.Registry[app]()
+		if t.server.Arch == "" && (op.mode == "install" || op.mode == "update") &&
+			component.NeedsArch(t.serverFor(app)) {
+			arch, err := session.Arch()
+			if err != nil {
+				logger.Printf("%s of %s failed: %v", op.mode, app, err)
+				o.status, o.err = "failed", err
+				failed = true
+				outs = append(outs, o)
+				continue
+			}
+			logger.Printf("🔎 Architecture: %s", arch)
+			t.server.Arch = arch
+		}
+
+		start := time.Now()
+
+		server := t.serverFor(app)
+		res, err := session.Run(app+" "+op.mode, op.steps(component, server))
+		if err == nil && op.healthTimeout > 0 {
+			err = session.WaitHealthy(component.Service(server), op.healthTimeout)
+		}
+
+		o.duration = time.Since(start)
+		if err != nil {
+			logger.Printf("%s of %s failed: %v", op.mode, app, err)
+			o.status, o.err = "failed", err
+			failed = true
+		} else {
+			o.status = res.Status()
+			if op.mode != "uninstall" {
+				o.version = deployedVersion(session, component.Service(server))
+			}
+		}
+		outs = append(outs, o)
+	}
+	return outs
+}
+
+// versionPattern picks the version number out of a "--version" banner such
+// as "prometheus, version 3.5.0 (branch: HEAD, ...)".
+var versionPattern = regexp.MustCompile(`version (\S+)`)
+
+// deployedVersion reports the release or commit now running on the host,
+// or "" if it cannot be determined.
+func deployedVersion(session *executor.Session, svc executor.Service) string {
+	if svc.Version == "" {
+		return ""
+	}
+	line, err := session.Revision(svc.Version)
+	if err != nil {
+		return ""
+	}
+	if m := versionPattern.FindStringSubmatch(line); m != nil {
+		return m[1]
+	}
+	return line
+}
+
+// printSummary writes a host × component table and returns the number of
+// failed outcomes.
+func printSummary(outs []outcome) int {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "HOST\tCOMPONENT\tSTATUS\tVERSION\tDURATION")
+
+	failures := 0
+	for _, o := range outs {
+		if o.status == "failed" {
+			failures++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", o.host, o.app, o.status, dash(o.version), o.duration.Round(100*time.Millisecond))
+	}
+	w.Flush()
+
+	for _, o := range outs {
+		if o.err != nil {
+			fmt.Printf("%s %s: %v\n", o.host, o.app, o.err)
+		}
+	}
+	return failures
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	models "github.com/elsgaard/firstmate/internal"
+)
+
+func TestApplyRolling(t *testing.T) {
+	tests := []struct {
+		name       string
+		serial     int
+		maxFailPct int
+		apps       map[string][]string // host -> apps, default one app "x"
+		failing    []string            // host/app outcomes that fail
+		batches    string              // hosts applied, batches separated by |
+		want       string              // host/app=status, in order
+	}{
+		{
+			name:    "all succeed",
+			serial:  2,
+			batches: "a,b|c,d|e",
+			want:    "a/x=ok b/x=ok c/x=ok d/x=ok e/x=ok",
+		},
+		{
+			name:    "first failure halts with no allowance",
+			serial:  2,
+			failing: []string{"b/x"},
+			batches: "a,b",
+			want:    "a/x=ok b/x=failed c/x=skipped d/x=skipped e/x=skipped",
+		},
+		{
+			name:       "failures at the limit continue",
+			serial:     1,
+			maxFailPct: 40,
+			failing:    []string{"a/x", "c/x"},
+			batches:    "a|b|c|d|e",
+			want:       "a/x=failed b/x=ok c/x=failed d/x=ok e/x=ok",
+		},
+		{
+			name:       "failures over the limit halt",
+			serial:     1,
+			maxFailPct: 40,
+			failing:    []string{"a/x", "b/x", "c/x"},
+			batches:    "a|b|c",
+			want:       "a/x=failed b/x=failed c/x=failed d/x=skipped e/x=skipped",
+		},
+		{
+			name:       "failures count hosts not components",
+			serial:     1,
+			maxFailPct: 20,
+			apps:       map[string][]string{"a": {"x", "y"}},
+			failing:    []string{"a/x", "a/y"},
+			batches:    "a|b|c|d|e",
+			want:       "a/x=failed a/y=failed b/x=ok c/x=ok d/x=ok e/x=ok",
+		},
+		{
+			name:       "skipped hosts list every component",
+			serial:     3,
+			maxFailPct: 20,
+			apps:       map[string][]string{"e": {"x", "y"}},
+			failing:    []string{"a/x", "b/x"},
+			batches:    "a,b,c",
+			want:       "a/x=failed b/x=failed c/x=ok d/x=skipped e/x=skipped e/y=skipped",
+		},
+	}
+
+	defer func() { applyBatch = applyAll }()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var targets []target
+			for _, host := range []string{"a", "b", "c", "d", "e"} {
+				apps := tt.apps[host]
+				if apps == nil {
+					apps = []string{"x"}
+				}
+				targets = append(targets, target{server: models.Server{FQDN: host}, apps: apps})
+			}
+
+			var batches []string
+			applyBatch = func(batch []target, op operation, forks int) []outcome {
+				var hosts []string
+				var outs []outcome
+				for _, t := range batch {
+					hosts = append(hosts, t.server.FQDN)
+					for _, app := range t.apps {
+						o := outcome{host: t.server.FQDN, app: app, status: "ok"}
+						if slices.Contains(tt.failing, t.server.FQDN+"/"+app) {
+							o.status = "failed"
+						}
+						outs = append(outs, o)
+					}
+				}
+				batches = append(batches, strings.Join(hosts, ","))
+				return outs
+			}
+
+			outs := applyRolling(targets, operation{mode: "update"}, tt.serial, 5, tt.maxFailPct)
+
+			if got := strings.Join(batches, "|"); got != tt.batches {
+				t.Errorf("batches = %q, want %q", got, tt.batches)
+			}
+			var got []string
+			for _, o := range outs {
+				got = append(got, fmt.Sprintf("%s/%s=%s", o.host, o.app, o.status))
+			}
+			if g := strings.Join(got, " "); g != tt.want {
+				t.Errorf("outcomes = %q, want %q", g, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/elsgaard/firstmate/internal/servercomponents"
+	"github.com/elsgaard/firstmate/internal/servercomponents/executor"
+)
+
+// diffResult holds the file differences of one component on one host.
+type diffResult struct {
+	host  string
+	app   string
+	diffs []executor.FileDiff
+	err   error
+}
+
+// diff prints a unified diff between each component's rendered files on the
+// host and the content firstmate would write. It exits 1 when any file
+// differs and 4 when a host could not be checked.
+func diff(args []string) {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	flags := addCommonFlags(fs)
+	forks := fs.Int("forks", 5, "Number of hosts processed in parallel")
+
+	fs.Parse(args)
+
+	targets := flags.targets(true)
+
+	results := parallel(targets, *forks, func(t target) []diffResult {
+		var results []diffResult
+		for _, app := range t.apps {
+			files := servercomponents.Registry[app]().Service(t.serverFor(app)).Files
+			diffs, err := executor.Diff(t.server, files)
+			results = append(results, diffResult{host: t.server.FQDN, app: app, diffs: diffs, err: err})
+		}
+		return results
+	})
+
+	drift, failed := false, false
+	for _, r := range results {
+		if r.err != nil {
+			fmt.Printf("### %s on %s: %v\n", r.app, r.host, r.err)
+			failed = true
+			continue
+		}
+		for _, d := range r.diffs {
+			if d.Diff == "" {
+				continue
+			}
+			drift = true
+			fmt.Printf("### %s on %s: %s\n%s\n", r.app, r.host, d.Path, d.Diff)
+		}
+	}
+
+	switch {
+	case failed:
+		os.Exit(4)
+	case drift:
+		os.Exit(1)
+	}
+	fmt.Println("No differences.")
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	models "github.com/elsgaard/firstmate/internal"
+)
+
+// commonFlags are the target and credential flags shared by every command.
+type commonFlags struct {
+	fs *flag.FlagSet
+
+	app           *string
+	host          *string
+	inventoryPath *string
+	limit         *string
+	group         *string
+	user          *string
+	pass          *string
+	identity      *string
+	knownHosts    *string
+	tofu          *bool
+	push          *bool
+	buildLocal    *bool
+	backupDir     *string
+	gh_user       *string
+	gh_pass       *string
+	gh_key        *string
+	f5_user       *string
+	f5_pass       *string
+	version       *string
+	ref           *string
+	sets          setFlag
+}
+
+// setFlag collects repeated --set component.key=value flags.
+type setFlag []string
+
+func (s *setFlag) String() string { return strings.Join(*s, ",") }
+
+func (s *setFlag) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+func addCommonFlags(fs *flag.FlagSet) *commonFlags {
+	f := &commonFlags{
+		fs:            fs,
+		app:           fs.String("app", "", "Application name"),
+		host:          fs.String("host", "", "Target host (e.g. server.example.com)"),
+		inventoryPath: fs.String("inventory", "", "Inventory file listing hosts and their components"),
+		limit:         fs.String("limit", "", "Restrict --inventory to hosts or groups (comma-separated, globs allowed)"),
+		group:         fs.String("group", "", "Restrict --inventory to the hosts of this group"),
+		user:          fs.String("user", os.Getenv("SSH_USER"), "SSH username, must be root (or SSH_USER)"),
+		pass:          fs.String("pass", os.Getenv("SSH_PASS"), "SSH password fallback (or SSH_PASS)"),
+		identity:      fs.String("identity-file", os.Getenv("SSH_IDENTITY_FILE"), "SSH private key (or SSH_IDENTITY_FILE)"),
+		knownHosts:    fs.String("known-hosts", "", "known_hosts file (default ~/.ssh/known_hosts)"),
+		tofu:          fs.Bool("trust-on-first-use", false, "Record host keys of unknown hosts"),
+		push:          fs.Bool("push", false, "Fetch artifacts on this machine and upload them to the hosts"),
+		buildLocal:    fs.Bool("build-local", false, "Build source components on this machine and upload the binaries"),
+		backupDir:     fs.String("backup-dir", "", "Download pre-update database backups into this directory"),
+		gh_user:       fs.String("gh_user", os.Getenv("GITHUB_USER"), "github username (or GITHUB_USER)"),
+		gh_pass:       fs.String("gh_pass", os.Getenv("GITHUB_PASS"), "github password (or GITHUB_PASS)"),
+		gh_key:        fs.String("gh_key", os.Getenv("GITHUB_KEY"), "SSH private key file for github, used instead of gh_pass (or GITHUB_KEY)"),
+		f5_user:       fs.String("f5_user", os.Getenv("F5_USER"), "F5 username for f5exporter (or F5_USER)"),
+		f5_pass:       fs.String("f5_pass", os.Getenv("F5_PASS"), "F5 password for f5exporter (or F5_PASS)"),
+	}
+	f.version = fs.String("version", "", "Release to install or update --app to, e.g. 3.5.0 (same as --set <app>.version=...)")
+	f.ref = fs.String("ref", "", "Git branch, tag or commit of --app to deploy (same as --set <app>.ref=...)")
+	fs.Var(&f.sets, "set", "Override a component parameter, component.key=value (repeatable)")
+	return f
+}
+
+// base returns the server settings shared by all targets.
+func (f *commonFlags) base() models.Server {
+	return models.Server{
+		User:            *f.user,
+		Pass:            *f.pass,
+		IdentityFile:    *f.identity,
+		KnownHosts:      *f.knownHosts,
+		TrustOnFirstUse: *f.tofu,
+		Push:            *f.push,
+		BuildLocal:      *f.buildLocal,
+		BackupDir:       *f.backupDir,
+		GHUser:          *f.gh_user,
+		GHPass:          *f.gh_pass,
+		GHKey:           readKeyFile(*f.gh_key),
+		F5User:          *f.f5_user,
+		F5Pass:          *f.f5_pass,
+	}
+}
+
+// targets resolves the hosts and components to act on, from --inventory or
+// from --host and --app. With connect set, every target must have SSH
+// credentials. It exits on invalid input.
+func (f *commonFlags) targets(connect bool) []target {
+	base := f.base()
+
+	var targets []target
+	if *f.inventoryPath != "" {
+		var err error
+		targets, err = inventoryTargets(*f.inventoryPath, *f.limit, *f.group, *f.app, base)
+		if err == nil && connect {
+			err = checkCredentials(targets)
+		}
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(2)
+		}
+	} else {
+		if *f.limit != "" {
+			fmt.Println("Error: --limit needs --inventory")
+			os.Exit(2)
+		}
+		if *f.group != "" {
+			fmt.Println("Error: --group needs --inventory")
+			os.Exit(2)
+		}
+		if connect {
+			require(f.fs,
+				"--app", *f.app,
+				"--host", *f.host,
+				"--user", *f.user,
+			)
+
+			if !hasCredentials(base) {
+				fmt.Println("Error: no SSH credentials: use --identity-file, an ssh-agent (SSH_AUTH_SOCK) or --pass")
+				f.fs.Usage()
+				os.Exit(2)
+			}
+		} else {
+			require(f.fs,
+				"--app", *f.app,
+				"--host", *f.host,
+			)
+		}
+
+		server := base
+		server.ID = 1
+		server.FQDN = *f.host
+		targets = []target{{server: server, apps: []string{*f.app}}}
+	}
+
+	checkApps(targets)
+
+	sets := f.sets
+	if *f.version != "" {
+		if *f.app == "" {
+			fmt.Println("Error: --version needs --app")
+			os.Exit(2)
+		}
+		sets = append(sets, *f.app+".version="+*f.version)
+	}
+	if *f.ref != "" {
+		if *f.app == "" {
+			fmt.Println("Error: --ref needs --app")
+			os.Exit(2)
+		}
+		sets = append(sets, *f.app+".ref="+*f.ref)
+	}
+
+	overrides, err := parseSets(sets, *f.app)
+	if err == nil {
+		setParams(targets, overrides)
+		err = checkParams(targets)
+	}
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(2)
+	}
+	return targets
+}
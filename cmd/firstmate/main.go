@@ -5,10 +5,9 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"slices"
 	"strings"
-
-	models "github.com/elsgaard/firstmate/internal"
-	"github.com/elsgaard/firstmate/internal/servercomponents"
+	"time"
 )
 
 func main() {
@@ -25,6 +24,16 @@ func main() {
 		run(os.Args[2:], "install")
 	case "update":
 		run(os.Args[2:], "update")
+	case "uninstall":
+		run(os.Args[2:], "uninstall")
+	case "rollback":
+		run(os.Args[2:], "rollback")
+	case "plan":
+		plan(os.Args[2:])
+	case "status":
+		status(os.Args[2:])
+	case "diff":
+		diff(os.Args[2:])
 	default:
 		fmt.Println("Unknown command:", os.Args[1])
 		usage()
@@ -34,50 +43,40 @@ func main() {
 
 func run(args []string, mode string) {
 	fs := flag.NewFlagSet(mode, flag.ExitOnError)
-
-	app := fs.String("app", "", "Application name")
-	host := fs.String("host", "", "Target host (e.g. server.example.com)")
-	user := fs.String("user", os.Getenv("SSH_USER"), "SSH username (or SSH_USER)")
-	pass := fs.String("pass", os.Getenv("SSH_PASS"), "SSH password (or SSH_PASS)")
-	gh_user := fs.String("gh_user", os.Getenv("GITHUB_USER"), "github username (or GITHUB_USER)")
-	gh_pass := fs.String("gh_pass", os.Getenv("GITHUB_PASS"), "github password (or GITHUB_PASS)")
+	flags := addCommonFlags(fs)
+	forks := fs.Int("forks", 5, "Number of hosts processed in parallel")
+	serial := fs.Int("serial", 0, "Roll out to this many hosts at a time, halting on failure (0 processes all at once)")
+	maxFail := fs.Int("max-fail-percentage", 0, "With --serial, percentage of hosts allowed to fail before the rollout halts")
+
+	purge := new(bool)
+	healthTimeout := new(time.Duration)
+	if mode == "uninstall" {
+		purge = fs.Bool("purge", false, "Also delete configuration and data")
+	} else {
+		healthTimeout = fs.Duration("health-timeout", time.Minute, "How long to wait for each component to become healthy (0 skips the check)")
+	}
 
 	fs.Parse(args)
 
-	require(fs,
-		"--app", *app,
-		"--host", *host,
-		"--user", *user,
-		"--pass", *pass,
-	)
-
-	server := models.Server{
-		ID:     1,
-		FQDN:   *host,
-		User:   *user,
-		Pass:   *pass,
-		GHUser: *gh_user,
-		GHPass: *gh_pass,
-	}
+	targets := flags.targets(true)
 
-	factory, ok := servercomponents.Registry[*app]
-	if !ok {
-		fmt.Println("Unknown app:", *app)
-		os.Exit(3)
+	if mode == "uninstall" {
+		// Remove components in the reverse order they were installed in.
+		for _, t := range targets {
+			slices.Reverse(t.apps)
+		}
 	}
 
-	component := factory()
+	op := newOperation(mode, *purge)
+	op.healthTimeout = *healthTimeout
 
-	var err error
-	switch mode {
-	case "install":
-		err = component.Deploy(server)
-	case "update":
-		err = component.Update(server)
+	var outs []outcome
+	if *serial > 0 {
+		outs = applyRolling(targets, op, *serial, *forks, *maxFail)
+	} else {
+		outs = applyAll(targets, op, *forks)
 	}
-
-	if err != nil {
-		fmt.Printf("%s failed: %v\n", strings.Title(mode), err)
+	if printSummary(outs) > 0 {
 		os.Exit(4)
 	}
 }
@@ -99,16 +98,67 @@ func require(fs *flag.FlagSet, pairs ...string) {
 	}
 }
 
+// readKeyFile returns the contents of the key file at path, or "" if path is
+// empty. It exits when the file cannot be read.
+func readKeyFile(path string) string {
+	if path == "" {
+		return ""
+	}
+	key, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(2)
+	}
+	return string(key)
+}
+
 func usage() {
-	fmt.Println(`Usage:
-  firstmate install [flags]
-  firstmate update  [flags]
+	fmt.Print(`Usage:
+  firstmate install   [flags] [--health-timeout 1m]
+  firstmate update    [flags] [--health-timeout 1m]
+  firstmate uninstall [flags] [--purge]
+  firstmate rollback  [flags] [--health-timeout 1m]
+  firstmate plan      [flags] [--op install|update|uninstall|rollback]
+  firstmate status    [flags]
+  firstmate diff      [flags]
 
 Flags:
-  --app   Application name
-  --host  Target host
-  --user  SSH user (or SSH_USER)
-  --pass  SSH password (or SSH_PASS)
+  --app            Application name
+  --host           Target host
+  --inventory      Inventory file listing hosts, groups and components
+  --limit          Restrict --inventory to hosts or groups (comma-separated,
+                   globs allowed)
+  --group          Restrict --inventory to the hosts of one group
+  --forks          Number of hosts processed in parallel (default 5)
+  --serial         Roll out to this many hosts at a time (install, update,
+                   rollback, uninstall)
+  --max-fail-percentage
+                   Share of hosts allowed to fail before a --serial rollout
+                   halts (default 0)
+  --version        Release of --app to install or update to (prometheus,
+                   alertmanager, nodeexp)
+  --ref            Git branch, tag or commit of --app to deploy (source
+                   components)
+  --set            Override a component parameter, component.key=value
+                   (repeatable)
+  --user           SSH user, must be root (or SSH_USER)
+  --identity-file  SSH private key (or SSH_IDENTITY_FILE)
+  --pass           SSH password fallback (or SSH_PASS)
+  --known-hosts    known_hosts file (default ~/.ssh/known_hosts)
+  --trust-on-first-use
+                   Record host keys of hosts not yet in known_hosts
+  --push           Fetch release archives and git checkouts on this machine and
+                   upload them, for hosts without internet access
+  --backup-dir     Also download pre-update database backups into this directory
+  --build-local    Build source components here (make build, cross-compiled for
+                   the host) and upload only the binary and its assets
+  --gh_user        GitHub user (or GITHUB_USER)
+  --gh_pass        GitHub token (or GITHUB_PASS)
+  --gh_key         GitHub SSH key file, used instead of a token (or GITHUB_KEY)
+  --f5_user        F5 user for f5exporter (or F5_USER)
+  --f5_pass        F5 password for f5exporter (or F5_PASS)
+
+See README.md for details.
 `)
 }
 
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/elsgaard/firstmate/internal/servercomponents"
+	"github.com/elsgaard/firstmate/internal/servercomponents/executor"
+)
+
+// plan prints the shell commands install, update, uninstall or rollback
+// would run, with secrets masked, without connecting to any host.
+func plan(args []string) {
+	fs := flag.NewFlagSet("plan", flag.ExitOnError)
+	flags := addCommonFlags(fs)
+	op := fs.String("op", "install", "Operation to plan: install, update, uninstall or rollback")
+	purge := fs.Bool("purge", false, "Plan uninstall with --purge")
+
+	fs.Parse(args)
+
+	if !slices.Contains([]string{"install", "update", "uninstall", "rollback"}, *op) {
+		fmt.Println("Error: --op must be install, update, uninstall or rollback")
+		os.Exit(2)
+	}
+
+	targets := flags.targets(false)
+
+	for _, t := range targets {
+		for _, app := range t.apps {
+			component := servercomponents.Registry[app]()
+			server := t.serverFor(app)
+
+			var steps []executor.Step
+			switch *op {
+			case "install":
+				steps = component.InstallSteps(server)
+			case "update":
+				steps = component.UpdateSteps(server)
+			case "uninstall":
+				steps = component.UninstallSteps(server, *purge)
+			case "rollback":
+				steps = component.RollbackSteps(server)
+			}
+
+			fmt.Printf("### %s %s on %s (%d steps)\n", *op, app, t.server.FQDN, len(steps))
+			for i, step := range steps {
+				fmt.Printf("\n# %d.%s\n", i+1, stepNote(step))
+				if step.Check != "" {
+					fmt.Printf("# skipped if: %s\n", step.Check)
+				}
+				if f := step.Upload; f != nil {
+					fmt.Printf("# owner %s, mode %04o\n", f.Owner, f.Mode)
+					fmt.Print(executor.Redact(f.Data(), t.server.Secrets()...))
+					continue
+				}
+				if d := step.Download; d != nil {
+					fmt.Printf("# download %s to %s\n", d.Remote, d.Local)
+					continue
+				}
+				if a := step.Push; a != nil {
+					fmt.Printf("# upload %s from %s\n", a.HostPath(), executor.CacheDir())
+				}
+				fmt.Println(executor.Redact(step.Cmd, t.server.Secrets()...))
+			}
+			fmt.Println()
+		}
+	}
+}
+
+// stepNote describes a step's name and error policy for plan output.
+func stepNote(step executor.Step) string {
+	var note []string
+	if step.Name != "" {
+		note = append(note, step.Name)
+	}
+	if step.ReadOnly {
+		note = append(note, "(read-only)")
+	}
+	if step.AfterChange {
+		note = append(note, "(runs after any change)")
+	}
+	switch step.Policy {
+	case executor.Ignorable:
+		note = append(note, "(errors ignored)")
+	case executor.Retryable:
+		note = append(note, fmt.Sprintf("(retried up to %d times)", step.Attempts))
+	}
+	if len(note) == 0 {
+		return ""
+	}
+	return " " + strings.Join(note, " ")
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/elsgaard/firstmate/internal/servercomponents"
+	"github.com/elsgaard/firstmate/internal/servercomponents/executor"
+)
+
+// statusRow is the live state of one component on one host.
+type statusRow struct {
+	host   string
+	app    string
+	svc    executor.Service
+	report executor.Report
+	err    error
+}
+
+// status reports the live state of components without changing anything.
+func status(args []string) {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	flags := addCommonFlags(fs)
+	forks := fs.Int("forks", 5, "Number of hosts processed in parallel")
+
+	fs.Parse(args)
+
+	targets := flags.targets(true)
+
+	rows := parallel(targets, *forks, func(t target) []statusRow {
+		var rows []statusRow
+		for _, app := range t.apps {
+			svc := servercomponents.Registry[app]().Service(t.serverFor(app))
+			report, err := executor.Inspect(t.server, svc)
+			rows = append(rows, statusRow{host: t.server.FQDN, app: app, svc: svc, report: report, err: err})
+		}
+		return rows
+	})
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "HOST\tCOMPONENT\tUNIT\tENABLED\tACTIVE\tVERSION\tFILES\tPORT")
+
+	failed := false
+	for _, r := range rows {
+		if r.err != nil {
+			fmt.Fprintf(w, "%s\t%s\terror: %v\n", r.host, r.app, r.err)
+			failed = true
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			r.host, r.app, unitState(r), dash(r.report.Enabled), dash(r.report.Active),
+			dash(r.report.Version), filesState(r.report), portState(r))
+	}
+	w.Flush()
+
+	if failed {
+		os.Exit(4)
+	}
+}
+
+func unitState(r statusRow) string {
+	switch {
+	case r.svc.Unit == "":
+		return "-"
+	case r.report.Loaded:
+		return "present"
+	}
+	return "missing"
+}
+
+// filesState summarises the rendered files, e.g. "in sync" or "1 differs".
+func filesState(rep executor.Report) string {
+	if len(rep.Files) == 0 {
+		return "-"
+	}
+
+	counts := map[string]int{}
+	for _, state := range rep.Files {
+		counts[state]++
+	}
+
+	var parts []string
+	for _, state := range slices.Sorted(maps.Keys(counts)) {
+		if state != executor.FileInSync {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[state], state))
+		}
+	}
+	if len(parts) == 0 {
+		return executor.FileInSync
+	}
+	return strings.Join(parts, ", ")
+}
+
+func portState(r statusRow) string {
+	switch {
+	case r.svc.Port == 0:
+		return "-"
+	case r.report.Listening:
+		return fmt.Sprintf("%d listening", r.svc.Port)
+	}
+	return fmt.Sprintf("%d closed", r.svc.Port)
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
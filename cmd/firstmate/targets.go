@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	models "github.com/elsgaard/firstmate/internal"
+	"github.com/elsgaard/firstmate/internal/inventory"
+	"github.com/elsgaard/firstmate/internal/servercomponents"
+	"github.com/elsgaard/firstmate/internal/servercomponents/executor"
+)
+
+// target is a host together with the components to apply to it, in order.
+type target struct {
+	server models.Server
+	apps   []string
+	params map[string]map[string]string // component -> parameter overrides
+}
+
+// serverFor returns the target's server with app's parameter overrides.
+func (t target) serverFor(app string) models.Server {
+	s := t.server
+	s.Params = t.params[app]
+	return s
+}
+
+// inventoryTargets resolves the hosts selected by limit and, if set, in
+// group. Credentials missing from the inventory fall back to those in base;
+// when app is set only that component is applied, and only on hosts that
+// list it.
+func inventoryTargets(path, limit, group, app string, base models.Server) ([]target, error) {
+	inv, err := inventory.Load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	hosts, err := inv.Select(limit)
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []target
+	for _, h := range hosts {
+		if group != "" && !slices.Contains(h.Groups, group) {
+			continue
+		}
+
+		server := h.Server()
+		if server.User == "" {
+			server.User = base.User
+		}
+		if server.Pass == "" {
+			server.Pass = base.Pass
+		}
+		if server.IdentityFile == "" {
+			server.IdentityFile = base.IdentityFile
+		}
+		server.Push = server.Push || base.Push
+		server.BuildLocal = server.BuildLocal || base.BuildLocal
+		server.BackupDir = base.BackupDir
+		server.KnownHosts = base.KnownHosts
+		server.TrustOnFirstUse = base.TrustOnFirstUse
+		server.GHUser = base.GHUser
+		server.GHPass = base.GHPass
+		server.GHKey = base.GHKey
+		server.F5User = base.F5User
+		server.F5Pass = base.F5Pass
+
+		apps := h.Components
+		if app != "" {
+			if !slices.Contains(apps, app) {
+				continue
+			}
+			apps = []string{app}
+		}
+
+		targets = append(targets, target{server: server, apps: apps, params: h.Params})
+	}
+
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no hosts in %s match the selection", path)
+	}
+	return targets, nil
+}
+
+// checkCredentials reports the first target that cannot be logged in to.
+func checkCredentials(targets []target) error {
+	for _, t := range targets {
+		if t.server.User == "" {
+			return fmt.Errorf("host %s: no SSH user", t.server.FQDN)
+		}
+		if !hasCredentials(t.server) {
+			return fmt.Errorf("host %s: no SSH credentials", t.server.FQDN)
+		}
+	}
+	return nil
+}
+
+// checkApps exits with code 3 if any target names an unknown component.
+func checkApps(targets []target) {
+	for _, t := range targets {
+		for _, app := range t.apps {
+			if _, ok := servercomponents.Registry[app]; !ok {
+				fmt.Printf("Unknown app: %s (host %s)\n", app, t.server.FQDN)
+				os.Exit(3)
+			}
+		}
+	}
+}
+
+// parseSets turns --set values of the form component.key=value into
+// parameter overrides. The component may be left out when app is set.
+func parseSets(sets []string, app string) (map[string]map[string]string, error) {
+	params := map[string]map[string]string{}
+	for _, set := range sets {
+		key, value, ok := strings.Cut(set, "=")
+		if !ok {
+			return nil, fmt.Errorf("--set %s: expected key=value", set)
+		}
+
+		component, key, ok := strings.Cut(key, ".")
+		if !ok {
+			if app == "" {
+				return nil, fmt.Errorf("--set %s: name the component (component.key=value) or use --app", set)
+			}
+			component, key = app, component
+		}
+
+		if params[component] == nil {
+			params[component] = map[string]string{}
+		}
+		params[component][key] = value
+	}
+	return params, nil
+}
+
+// setParams applies overrides on top of every target's parameters.
+func setParams(targets []target, overrides map[string]map[string]string) {
+	for i := range targets {
+		params := maps.Clone(targets[i].params)
+		if params == nil {
+			params = map[string]map[string]string{}
+		}
+		for app, values := range overrides {
+			merged := maps.Clone(params[app])
+			if merged == nil {
+				merged = map[string]string{}
+			}
+			maps.Copy(merged, values)
+			params[app] = merged
+		}
+		targets[i].params = params
+	}
+}
+
+// checkParams reports the first parameter override that names an unknown
+// component or parameter, or has a value of the wrong type.
+func checkParams(targets []target) error {
+	for _, t := range targets {
+		for _, app := range slices.Sorted(maps.Keys(t.params)) {
+			newComponent, ok := servercomponents.Registry[app]
+			if !ok {
+				return fmt.Errorf("host %s: parameters for unknown app %s", t.server.FQDN, app)
+			}
+			if err := executor.CheckParams(newComponent().Params(), t.params[app]); err != nil {
+				return fmt.Errorf("host %s: %s: %w", t.server.FQDN, app, err)
+			}
+		}
+	}
+	return nil
+}
+
+func hasCredentials(s models.Server) bool {
+	return s.IdentityFile != "" || s.Pass != "" || os.Getenv("SSH_AUTH_SOCK") != ""
+}
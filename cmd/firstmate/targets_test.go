@@ -0,0 +1,106 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	models "github.com/elsgaard/firstmate/internal"
+)
+
+func TestParseSets(t *testing.T) {
+	tests := []struct {
+		name    string
+		sets    []string
+		app     string
+		want    map[string]map[string]string
+		wantErr string
+	}{
+		{name: "none", want: map[string]map[string]string{}},
+		{
+			name: "component prefixes",
+			sets: []string{"prometheus.retention=30d", "nodeexp.port=9182", "prometheus.version=3.5.0"},
+			want: map[string]map[string]string{
+				"prometheus": {"retention": "30d", "version": "3.5.0"},
+				"nodeexp":    {"port": "9182"},
+			},
+		},
+		{
+			name: "later set wins",
+			sets: []string{"nodeexp.port=9100", "nodeexp.port=9182"},
+			want: map[string]map[string]string{"nodeexp": {"port": "9182"}},
+		},
+		{
+			name: "value keeps dots and equals signs",
+			sets: []string{"prometheus.external_url=https://p.example.com/?a=b"},
+			want: map[string]map[string]string{"prometheus": {"external_url": "https://p.example.com/?a=b"}},
+		},
+		{
+			name: "empty value",
+			sets: []string{"sftrip.ref="},
+			want: map[string]map[string]string{"sftrip": {"ref": ""}},
+		},
+		{
+			name: "prefix defaults to --app",
+			sets: []string{"retention=30d", "nodeexp.port=9182"},
+			app:  "prometheus",
+			want: map[string]map[string]string{
+				"prometheus": {"retention": "30d"},
+				"nodeexp":    {"port": "9182"},
+			},
+		},
+		{name: "missing value", sets: []string{"prometheus.retention"}, wantErr: "expected key=value"},
+		{name: "missing prefix without --app", sets: []string{"retention=30d"}, wantErr: "name the component"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSets(tt.sets, tt.app)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseSets error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseSets = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetParams(t *testing.T) {
+	inventory := map[string]map[string]string{
+		"prometheus": {"retention": "15d", "external_url": "https://p.example.com"},
+	}
+	targets := []target{
+		{server: models.Server{FQDN: "a"}, params: inventory},
+		{server: models.Server{FQDN: "b"}},
+	}
+
+	setParams(targets, map[string]map[string]string{
+		"prometheus": {"retention": "30d"},
+		"nodeexp":    {"port": "9182"},
+	})
+
+	want := []map[string]map[string]string{
+		{
+			"prometheus": {"retention": "30d", "external_url": "https://p.example.com"},
+			"nodeexp":    {"port": "9182"},
+		},
+		{
+			"prometheus": {"retention": "30d"},
+			"nodeexp":    {"port": "9182"},
+		},
+	}
+	for i, tg := range targets {
+		if !reflect.DeepEqual(tg.params, want[i]) {
+			t.Errorf("host %s params = %v, want %v", tg.server.FQDN, tg.params, want[i])
+		}
+	}
+	if inventory["prometheus"]["retention"] != "15d" {
+		t.Errorf("setParams changed the inventory's params: %v", inventory)
+	}
+}
@@ -2,12 +2,15 @@ module github.com/elsgaard/firstmate
 
 go 1.24.0
 
-require github.com/sfreiberg/simplessh v0.0.0-20220719182921-185eafd40485
+require (
+	github.com/sfreiberg/simplessh v0.0.0-20220719182921-185eafd40485
+	golang.org/x/crypto v0.43.0
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
 	github.com/davidmz/go-pageant v1.0.2 // indirect
 	github.com/kr/fs v0.1.0 // indirect
 	github.com/pkg/sftp v1.13.10 // indirect
-	golang.org/x/crypto v0.43.0 // indirect
 	golang.org/x/sys v0.37.0 // indirect
 )
@@ -0,0 +1,202 @@
+// internal/inventory/inventory.go
+package inventory
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/elsgaard/firstmate/internal"
+	"github.com/elsgaard/firstmate/internal/servercomponents/executor"
+	"gopkg.in/yaml.v3"
+)
+
+// Inventory is the set of hosts firstmate manages, as read from a YAML file:
+//
+//	defaults:
+//	  user: root
+//	  identity_file: ~/.ssh/id_ed25519
+//	  params:
+//	    nodeexp: {port: 9182}
+//	hosts:
+//	  - name: mon01.example.com
+//	    groups: [monitoring]
+//	    components: [ubuntu, nodeexp, prometheus, alertmanager]
+//	    params:
+//	      prometheus: {external_url: https://prometheus.example.com}
+//	  - name: edi01.example.com
+//	    groups: [edi]
+//	    pass: ${EDI_SSH_PASS}
+//	    components: [ubuntu, nodeexp, edicheck, sftrip]
+//
+// firstmate runs its commands and file uploads as the SSH user without sudo,
+// so user must be root. Values of the form ${VAR} are expanded from the
+// environment so that credentials need not be stored in the file. Params
+// override the defaults of each component's parameters.
+type Inventory struct {
+	Defaults Host   `yaml:"defaults"`
+	Hosts    []Host `yaml:"hosts"`
+}
+
+// Host is a single inventory entry. Empty fields inherit from Defaults.
+type Host struct {
+	Name         string   `yaml:"name"`
+	Groups       []string `yaml:"groups"`
+	User         string   `yaml:"user"`
+	Pass         string   `yaml:"pass"`
+	IdentityFile string   `yaml:"identity_file"`
+	Arch         string   `yaml:"arch"`        // skips architecture detection
+	Push         bool     `yaml:"push"`        // host has no internet access, push artifacts
+	BuildLocal   bool     `yaml:"build_local"` // host carries no toolchain, ship binaries
+	Components   []string `yaml:"components"`
+
+	// Params maps component names to parameter overrides.
+	Params map[string]map[string]string `yaml:"params"`
+
+	id int // position in the file, used as Server.ID
+}
+
+// Load reads and validates the inventory at path.
+func Load(path string) (*Inventory, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var inv Inventory
+	if err := yaml.Unmarshal(data, &inv); err != nil {
+		return nil, fmt.Errorf("parse inventory %s: %w", path, err)
+	}
+
+	seen := map[string]bool{}
+	for i := range inv.Hosts {
+		h := &inv.Hosts[i]
+		if h.Name == "" {
+			return nil, fmt.Errorf("inventory %s: host #%d has no name", path, i+1)
+		}
+		if seen[h.Name] {
+			return nil, fmt.Errorf("inventory %s: host %s listed twice", path, h.Name)
+		}
+		seen[h.Name] = true
+		h.id = i + 1
+		h.inherit(inv.Defaults)
+		if h.Arch != "" {
+			if h.Arch, err = executor.NormalizeArch(h.Arch); err != nil {
+				return nil, fmt.Errorf("inventory %s: host %s: %w", path, h.Name, err)
+			}
+		}
+	}
+
+	return &inv, nil
+}
+
+func (h *Host) inherit(d Host) {
+	if h.User == "" {
+		h.User = d.User
+	}
+	if h.Pass == "" {
+		h.Pass = d.Pass
+	}
+	if h.IdentityFile == "" {
+		h.IdentityFile = d.IdentityFile
+	}
+	if h.Arch == "" {
+		h.Arch = d.Arch
+	}
+	if !h.Push {
+		h.Push = d.Push
+	}
+	if !h.BuildLocal {
+		h.BuildLocal = d.BuildLocal
+	}
+	if len(h.Components) == 0 {
+		h.Components = d.Components
+	}
+
+	params := map[string]map[string]string{}
+	for _, src := range []map[string]map[string]string{d.Params, h.Params} {
+		for app, values := range src {
+			if params[app] == nil {
+				params[app] = map[string]string{}
+			}
+			for k, v := range values {
+				params[app][k] = os.ExpandEnv(v)
+			}
+		}
+	}
+	h.Params = params
+
+	h.User = os.ExpandEnv(h.User)
+	h.Pass = os.ExpandEnv(h.Pass)
+	h.IdentityFile = expandHome(os.ExpandEnv(h.IdentityFile))
+}
+
+// Select returns the hosts matching limit, a comma-separated list of host
+// names, group names or glob patterns over either. An empty limit selects
+// every host.
+func (inv *Inventory) Select(limit string) ([]Host, error) {
+	if limit == "" {
+		return inv.Hosts, nil
+	}
+
+	var patterns []string
+	for _, p := range strings.Split(limit, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			if _, err := path.Match(p, ""); err != nil {
+				return nil, fmt.Errorf("bad --limit pattern %q: %w", p, err)
+			}
+			patterns = append(patterns, p)
+		}
+	}
+
+	var hosts []Host
+	for _, h := range inv.Hosts {
+		if slices.ContainsFunc(patterns, h.Matches) {
+			hosts = append(hosts, h)
+		}
+	}
+
+	if len(hosts) == 0 {
+		return nil, errors.New("--limit matched no hosts")
+	}
+	return hosts, nil
+}
+
+// Matches reports whether pattern names the host or one of its groups.
+func (h Host) Matches(pattern string) bool {
+	if ok, _ := path.Match(pattern, h.Name); ok {
+		return true
+	}
+	for _, g := range h.Groups {
+		if ok, _ := path.Match(pattern, g); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Server returns the connection details for the host.
+func (h Host) Server() internal.Server {
+	return internal.Server{
+		ID:           h.id,
+		FQDN:         h.Name,
+		User:         h.User,
+		Pass:         h.Pass,
+		IdentityFile: h.IdentityFile,
+		Arch:         h.Arch,
+		Push:         h.Push,
+		BuildLocal:   h.BuildLocal,
+	}
+}
+
+func expandHome(p string) string {
+	if rest, ok := strings.CutPrefix(p, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return home + "/" + rest
+		}
+	}
+	return p
+}
@@ -0,0 +1,165 @@
+package inventory
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSelect(t *testing.T) {
+	inv := &Inventory{Hosts: []Host{
+		{Name: "mon01.example.com", Groups: []string{"monitoring"}},
+		{Name: "mon02.example.com", Groups: []string{"monitoring", "etcd"}},
+		{Name: "edi01.example.com", Groups: []string{"edi"}},
+		{Name: "etcd01.example.com", Groups: []string{"etcd"}},
+	}}
+
+	tests := []struct {
+		name    string
+		limit   string
+		want    []string
+		wantErr string
+	}{
+		{name: "empty selects all", limit: "", want: []string{"mon01.example.com", "mon02.example.com", "edi01.example.com", "etcd01.example.com"}},
+		{name: "host name", limit: "edi01.example.com", want: []string{"edi01.example.com"}},
+		{name: "group", limit: "etcd", want: []string{"mon02.example.com", "etcd01.example.com"}},
+		{name: "host glob", limit: "mon*", want: []string{"mon01.example.com", "mon02.example.com"}},
+		{name: "group glob", limit: "mon?toring", want: []string{"mon01.example.com", "mon02.example.com"}},
+		{name: "list keeps file order", limit: "etcd01.example.com, edi", want: []string{"edi01.example.com", "etcd01.example.com"}},
+		{name: "overlapping patterns select once", limit: "monitoring,mon01*", want: []string{"mon01.example.com", "mon02.example.com"}},
+		{name: "blank entries ignored", limit: ",edi,,", want: []string{"edi01.example.com"}},
+		{name: "no match", limit: "web*", wantErr: "matched no hosts"},
+		{name: "bad pattern", limit: "mon[", wantErr: "bad --limit pattern"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hosts, err := inv.Select(tt.limit)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Select(%q) error = %v, want %q", tt.limit, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, h := range hosts {
+				names = append(names, h.Name)
+			}
+			if !reflect.DeepEqual(names, tt.want) {
+				t.Errorf("Select(%q) = %v, want %v", tt.limit, names, tt.want)
+			}
+		})
+	}
+}
+
+func TestInherit(t *testing.T) {
+	t.Setenv("EDI_SSH_PASS", "hunter2")
+	t.Setenv("EXTERNAL_URL", "https://prometheus.example.com")
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defaults := Host{
+		User:         "root",
+		Pass:         "default-pass",
+		IdentityFile: "~/.ssh/id_ed25519",
+		Arch:         "amd64",
+		Push:         true,
+		Components:   []string{"ubuntu", "nodeexp"},
+		Params: map[string]map[string]string{
+			"nodeexp":    {"port": "9182"},
+			"prometheus": {"retention": "15d", "external_url": "http://localhost"},
+		},
+	}
+
+	tests := []struct {
+		name string
+		host Host
+		want Host
+	}{
+		{
+			name: "empty host takes the defaults",
+			host: Host{Name: "a"},
+			want: Host{
+				Name:         "a",
+				User:         "root",
+				Pass:         "default-pass",
+				IdentityFile: home + "/.ssh/id_ed25519",
+				Arch:         "amd64",
+				Push:         true,
+				Components:   []string{"ubuntu", "nodeexp"},
+				Params: map[string]map[string]string{
+					"nodeexp":    {"port": "9182"},
+					"prometheus": {"retention": "15d", "external_url": "http://localhost"},
+				},
+			},
+		},
+		{
+			name: "host values win and are expanded",
+			host: Host{
+				Name:         "b",
+				User:         "admin",
+				Pass:         "${EDI_SSH_PASS}",
+				IdentityFile: "/keys/b",
+				Arch:         "arm64",
+				BuildLocal:   true,
+				Components:   []string{"ubuntu", "edicheck"},
+			},
+			want: Host{
+				Name:         "b",
+				User:         "admin",
+				Pass:         "hunter2",
+				IdentityFile: "/keys/b",
+				Arch:         "arm64",
+				Push:         true,
+				BuildLocal:   true,
+				Components:   []string{"ubuntu", "edicheck"},
+				Params: map[string]map[string]string{
+					"nodeexp":    {"port": "9182"},
+					"prometheus": {"retention": "15d", "external_url": "http://localhost"},
+				},
+			},
+		},
+		{
+			name: "params merge per key",
+			host: Host{
+				Name: "c",
+				Params: map[string]map[string]string{
+					"prometheus": {"external_url": "${EXTERNAL_URL}"},
+					"sftrip":     {"ref": "v1.2.0"},
+				},
+			},
+			want: Host{
+				Name:         "c",
+				User:         "root",
+				Pass:         "default-pass",
+				IdentityFile: home + "/.ssh/id_ed25519",
+				Arch:         "amd64",
+				Push:         true,
+				Components:   []string{"ubuntu", "nodeexp"},
+				Params: map[string]map[string]string{
+					"nodeexp":    {"port": "9182"},
+					"prometheus": {"retention": "15d", "external_url": "https://prometheus.example.com"},
+					"sftrip":     {"ref": "v1.2.0"},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := tt.host
+			h.inherit(defaults)
+			if !reflect.DeepEqual(h, tt.want) {
+				t.Errorf("inherit:\n got %+v\nwant %+v", h, tt.want)
+			}
+		})
+	}
+
+	if got := defaults.Params["prometheus"]["external_url"]; got != "http://localhost" {
+		t.Errorf("inherit changed the defaults' params: external_url = %q", got)
+	}
+}
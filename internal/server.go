@@ -2,10 +2,34 @@
 package internal
 
 type Server struct {
-	ID     int
-	FQDN   string
-	User   string
-	Pass   string
-	GHUser string
-	GHPass string
+	ID              int
+	FQDN            string
+	User            string
+	Pass            string
+	IdentityFile    string
+	KnownHosts      string // known_hosts file, defaults to ~/.ssh/known_hosts
+	TrustOnFirstUse bool   // record unknown host keys instead of rejecting them
+	Arch            string // release architecture, e.g. amd64; detected when empty
+	Push            bool   // upload artifacts from the operator instead of downloading on the host
+	BuildLocal      bool   // build source components on the operator and upload the binaries
+	BackupDir       string // local directory receiving copies of pre-update database backups
+	GHUser          string
+	GHPass          string
+	GHKey           string // private SSH key for GitHub, preferred over GHPass
+	F5User          string
+	F5Pass          string
+
+	// Params overrides the parameters of the component being processed.
+	Params map[string]string
+}
+
+// Secrets returns the credential values that must never appear in output.
+func (s Server) Secrets() []string {
+	var secrets []string
+	for _, v := range []string{s.Pass, s.GHPass, s.GHKey, s.F5Pass} {
+		if v != "" {
+			secrets = append(secrets, v)
+		}
+	}
+	return secrets
 }
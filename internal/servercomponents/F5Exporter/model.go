@@ -1,89 +1,106 @@
 package F5Exporter
 
 import (
-	"fmt"
-	"log"
-	"strings"
-	"time"
-
 	"github.com/elsgaard/firstmate/internal"
-	"github.com/sfreiberg/simplessh"
+	"github.com/elsgaard/firstmate/internal/servercomponents/executor"
 )
 
 type Model struct{}
 
-func (m Model) Deploy(server internal.Server) error {
-	log.Printf("▶ Starting F5LTM Exporter deploy on %s", server.FQDN)
-	return m.executeRemoteCommands(server, m.getInstallCommands(server))
+// source is the repository the component is built from.
+var source = executor.Source{
+	Repo:   "TRUECOMMERCEDK/f5ltm_exporter",
+	Binary: "f5ltmexporterserver",
 }
 
-func (m Model) Update(server internal.Server) error {
-	log.Printf("▶ Starting F5LTM Exporter update on %s", server.FQDN)
-	return m.executeRemoteCommands(server, m.getUpdateCommands())
+// Params select the git ref and the settings rendered into the unit file.
+// With CredentialsFromEnv the exporter must read F5_USER and F5_PASS from its
+// environment; otherwise they are passed as flags and show up in ps.
+type Params struct {
+	Ref                string `param:"ref"`
+	TLSSkipVerify      bool   `param:"tls_skip_verify"`
+	CredentialsFromEnv bool   `param:"credentials_from_env"`
 }
 
-// executeRemoteCommands centralizes connection, execution, and error handling.
-func (m Model) executeRemoteCommands(server internal.Server, cmds []string) error {
-	client, err := simplessh.ConnectWithPassword(server.FQDN, server.User, server.Pass)
-	if err != nil {
-		return fmt.Errorf("SSH connection failed: %w", err)
-	}
-	defer client.Close()
-
-	for _, cmd := range cmds {
-		serverCmd := m.checkCustomAction(cmd)
-
-		log.Printf("→ Executing: %s", serverCmd)
-		out, err := client.Exec(serverCmd)
-		if err != nil {
-			log.Printf("⚠️ Command failed: %v", err)
-			continue // `return err` to fail-fast, for now just continue
-		}
-		if len(out) > 0 {
-			log.Printf("→ Output: %s", strings.TrimSpace(string(out)))
-		}
-
-		time.Sleep(500 * time.Millisecond) // gentle pacing between commands
-	}
+// defaults apply to anything not overridden with --set or the inventory.
+var defaults = Params{
+	Ref:           "main",
+	TLSSkipVerify: true,
+}
 
-	log.Println("✅ F5LTM Exporter operation completed successfully")
-	return nil
+func (m Model) UpdateSteps(server internal.Server) []executor.Step {
+	steps := []executor.Step{m.envFileStep(server)}
+	steps = append(steps, executor.UpdateSource(server, m.source(server), "origin", "main")...)
+	return append(steps,
+		executor.WriteFile(m.unitFile(server)),
+		executor.DaemonReload("f5ltm_exporter.service"),
+		executor.Cmd("systemctl restart f5ltm_exporter.service"),
+	)
 }
 
-func (m Model) getUpdateCommands() []string {
-	return []string{
-		"systemctl stop f5ltm_exporter.service",
-		"git -C /opt/f5ltm_exporter fetch origin main",
-		"git -C /opt/f5ltm_exporter reset --hard origin/main",
-		"cd /opt/f5ltm_exporter && make build",
-		"CUSTOM: CreateUnitFile",
-		"systemctl daemon-reload",
-		"systemctl restart f5ltm_exporter.service",
-	}
+func (m Model) InstallSteps(server internal.Server) []executor.Step {
+	steps := []executor.Step{m.envFileStep(server)}
+	steps = append(steps, executor.InstallSource(server, m.source(server))...)
+	return append(steps,
+		executor.WriteFile(m.unitFile(server)),
+		executor.DaemonReload("f5ltm_exporter.service"),
+		executor.EnableNow("f5ltm_exporter.service"),
+	)
+}
+
+func (m Model) RollbackSteps(server internal.Server) []executor.Step {
+	steps := []executor.Step{m.envFileStep(server)}
+	steps = append(steps, executor.RollbackSource(source)...)
+	return append(steps,
+		executor.WriteFile(m.unitFile(server)),
+		executor.DaemonReload("f5ltm_exporter.service"),
+		executor.Cmd("systemctl restart f5ltm_exporter.service"),
+	)
 }
 
-func (m Model) getInstallCommands(server internal.Server) []string {
-	return []string{
-		gitCloneCommand(server, "TRUECOMMERCEDK/f5ltm_exporter"),
-		"cd /opt/f5ltm_exporter && make build",
-		"CUSTOM: CreateUnitFile",
-		"systemctl daemon-reload",
-		"systemctl enable --now f5ltm_exporter.service",
+func (m Model) UninstallSteps(server internal.Server, purge bool) []executor.Step {
+	steps := append(executor.RemoveUnit("f5ltm_exporter.service"),
+		executor.RemoveSource(source),
+	)
+	if purge {
+		steps = append(steps,
+			executor.Cmd("rm -rf /etc/f5ltm_exporter"),
+		)
 	}
+	return steps
 }
 
-// Custom action dispatcher.
-func (m Model) checkCustomAction(action string) string {
-	if strings.HasSuffix(action, "CreateUnitFile") {
-		return m.createUnitFile()
+func (m Model) Service(server internal.Server) executor.Service {
+	return executor.Service{
+		Unit:    "f5ltm_exporter.service",
+		Files:   []executor.File{m.unitFile(server)},
+		Version: executor.SourceVersion(source),
 	}
-	return action
 }
 
-// CreateUnitFile returns a properly escaped heredoc for remote tee.
-func (m Model) createUnitFile() string {
-	return `sudo bash -c 'cat > /etc/systemd/system/f5ltm_exporter.service <<EOF
-[Unit]
+func (m Model) Params() any {
+	return defaults
+}
+
+// NeedsArch is true when the binary is built locally for the host.
+func (m Model) NeedsArch(server internal.Server) bool {
+	return server.BuildLocal
+}
+
+// source returns the repository at the configured ref.
+func (m Model) source(server internal.Server) executor.Source {
+	src := source
+	src.Ref = executor.Override(defaults, server.Params).Ref
+	return src
+}
+
+// unitFile returns the systemd unit.
+func (m Model) unitFile(server internal.Server) executor.File {
+	p := executor.Override(defaults, server.Params)
+
+	return executor.File{
+		Path: "/etc/systemd/system/f5ltm_exporter.service",
+		Content: executor.Render(`[Unit]
 Description=F5 LTM Exporter Service
 After=network.target
 StartLimitIntervalSec=0
@@ -94,18 +111,36 @@ Restart=always
 RestartSec=1
 User=root
 WorkingDirectory=/opt/f5ltm_exporter
-ExecStart=/opt/f5ltm_exporter/f5ltmexporterserver --f5-user=monitoring --f5-pass=TrueCom2024 --tls-skip-verify=true
+EnvironmentFile=/etc/f5ltm_exporter/f5ltm_exporter.env
+ExecStart=/opt/f5ltm_exporter/f5ltmexporterserver {{if not .CredentialsFromEnv}}--f5-user=${F5_USER} --f5-pass=${F5_PASS} {{end}}--tls-skip-verify={{.TLSSkipVerify}}
 
 [Install]
 WantedBy=multi-user.target
-EOF'`
+`, p),
+	}
 }
 
-func gitCloneCommand(s internal.Server, repo string) string {
-	return fmt.Sprintf(
-		"cd /opt && git clone https://%s:%s@github.com/%s.git",
-		s.GHUser,
-		s.GHPass,
-		repo,
+// envFileStep writes the F5 credentials to a root-only EnvironmentFile that
+// the unit reads, keeping them out of the world-readable unit file and out of
+// firstmate's commands. Without a password the existing file is kept, and the
+// step fails before anything else changes when there is none.
+func (m Model) envFileStep(server internal.Server) executor.Step {
+	if server.F5Pass == "" {
+		return executor.Custom("CheckEnvFile",
+			`test -s /etc/f5ltm_exporter/f5ltm_exporter.env || { echo "no F5 credentials on the host: set --f5_pass or F5_PASS" >&2; exit 1; }`,
+		).Observe()
+	}
+
+	user := server.F5User
+	if user == "" {
+		user = "monitoring"
+	}
+
+	const (
+		envFile = "/etc/f5ltm_exporter/f5ltm_exporter.env"
+		content = `printf 'F5_USER=%s\nF5_PASS=%s\n' "$F5_USER" "$F5_PASS"`
 	)
+	return executor.Custom("CreateEnvFile",
+		`umask 077 && mkdir -p /etc/f5ltm_exporter && `+content+` > `+envFile,
+	).Unless(content+` | cmp -s - `+envFile+` && `+executor.HasMode(envFile, "600")).WithSecretEnv("F5_USER", user).WithSecretEnv("F5_PASS", server.F5Pass)
 }
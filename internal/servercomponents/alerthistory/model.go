@@ -1,92 +1,105 @@
 package alerthistory
 
 import (
-	"fmt"
-	"log"
-	"strings"
-	"time"
-
 	"github.com/elsgaard/firstmate/internal"
-	"github.com/sfreiberg/simplessh"
+	"github.com/elsgaard/firstmate/internal/servercomponents/executor"
 )
 
 type Model struct{}
 
-func (m Model) Deploy(server internal.Server) error {
-	log.Printf("▶ Starting Alerthistory deploy on %s", server.FQDN)
-	return m.executeRemoteCommands(server, m.getInstallCommands(server))
+// source is the repository the component is built from.
+var source = executor.Source{
+	Repo:   "TRUECOMMERCEDK/alerthistory",
+	Binary: "alerthistoryserver",
 }
 
-func (m Model) Update(server internal.Server) error {
-	log.Printf("▶ Starting Alerthistory update on %s", server.FQDN)
-	return m.executeRemoteCommands(server, m.getUpdateCommands())
+// Params select the git ref and the settings rendered into the unit file.
+type Params struct {
+	Ref     string `param:"ref"`
+	Port    int    `param:"port"`
+	Backups int    `param:"backups"` // database backups kept on the host
 }
 
-// executeRemoteCommands centralizes connection, execution, and error handling.
-func (m Model) executeRemoteCommands(server internal.Server, cmds []string) error {
-	client, err := simplessh.ConnectWithPassword(server.FQDN, server.User, server.Pass)
-	if err != nil {
-		return fmt.Errorf("SSH connection failed: %w", err)
-	}
-	defer client.Close()
-
-	for _, cmd := range cmds {
-		serverCmd := m.checkCustomAction(cmd)
-
-		log.Printf("→ Executing: %s", serverCmd)
-		out, err := client.Exec(serverCmd)
-		if err != nil {
-			log.Printf("⚠️ Command failed: %v", err)
-			continue // `return err` to fail-fast, for now just continue
-		}
-		if len(out) > 0 {
-			log.Printf("→ Output: %s", strings.TrimSpace(string(out)))
-		}
-
-		time.Sleep(500 * time.Millisecond) // gentle pacing between commands
-	}
+// defaults apply to anything not overridden with --set or the inventory.
+var defaults = Params{
+	Ref:     "main",
+	Backups: 7,
+	Port:    8082,
+}
 
-	log.Println("✅ Alerthistory operation completed successfully")
-	return nil
+func (m Model) UpdateSteps(server internal.Server) []executor.Step {
+	steps := executor.BackupDB(server, "/var/lib/alerthistory/alerthistory.db", executor.Override(defaults, server.Params).Backups)
+	steps = append(steps, executor.UpdateSource(server, m.source(server), "origin", "main")...)
+	return append(steps,
+		executor.WriteFile(m.unitFile(server)),
+		executor.DaemonReload("alerthistory.service"),
+		executor.Cmd("systemctl restart alerthistory.service"),
+	)
 }
 
-func (m Model) getUpdateCommands() []string {
-	return []string{
-		"systemctl stop alerthistory.service",
-		"git -C /opt/alerthistory fetch origin main",
-		"git -C /opt/alerthistory reset --hard origin/main",
-		"cd /opt/alerthistory && make build",
-		"CUSTOM: CreateUnitFile",
-		"systemctl daemon-reload",
-		"systemctl restart alerthistory.service",
-	}
+func (m Model) InstallSteps(server internal.Server) []executor.Step {
+	return append(executor.InstallSource(server, m.source(server)),
+		executor.Cmd("mkdir -p /etc/alerthistory").Unless(executor.DirExists("/etc/alerthistory")),
+		executor.Cmd("mkdir -p /var/lib/alerthistory").Unless(executor.DirExists("/var/lib/alerthistory")),
+		executor.Cmd("chmod 755 /var/lib/alerthistory").Unless(executor.HasMode("/var/lib/alerthistory", "755")),
+		executor.WriteFile(m.unitFile(server)),
+		executor.DaemonReload("alerthistory.service"),
+		executor.EnableNow("alerthistory.service"),
+	)
 }
 
-func (m Model) getInstallCommands(server internal.Server) []string {
-	return []string{
-		gitCloneCommand(server, "TRUECOMMERCEDK/alerthistory"),
-		"cd /opt/alerthistory && make build",
-		"mkdir -p /etc/alerthistory",
-		"mkdir -p /var/lib/alerthistory",
-		"chmod 755 /var/lib/alerthistory",
-		"CUSTOM: CreateUnitFile",
-		"systemctl daemon-reload",
-		"systemctl enable --now alerthistory.service",
+func (m Model) RollbackSteps(server internal.Server) []executor.Step {
+	return append(executor.RollbackSource(source),
+		executor.WriteFile(m.unitFile(server)),
+		executor.DaemonReload("alerthistory.service"),
+		executor.Cmd("systemctl restart alerthistory.service"),
+	)
+}
+
+func (m Model) UninstallSteps(server internal.Server, purge bool) []executor.Step {
+	steps := append(executor.RemoveUnit("alerthistory.service"),
+		executor.RemoveSource(source),
+	)
+	if purge {
+		steps = append(steps,
+			executor.Cmd("rm -rf /etc/alerthistory /var/lib/alerthistory"),
+		)
 	}
+	return steps
 }
 
-// Custom action dispatcher.
-func (m Model) checkCustomAction(action string) string {
-	if strings.HasSuffix(action, "CreateUnitFile") {
-		return m.createUnitFile()
+func (m Model) Service(server internal.Server) executor.Service {
+	return executor.Service{
+		Unit:    "alerthistory.service",
+		Files:   []executor.File{m.unitFile(server)},
+		Version: executor.SourceVersion(source),
+		Port:    executor.Override(defaults, server.Params).Port,
 	}
-	return action
 }
 
-// CreateUnitFile returns a properly escaped heredoc for remote tee.
-func (m Model) createUnitFile() string {
-	return `sudo bash -c 'cat > /etc/systemd/system/alerthistory.service <<EOF
-[Unit]
+func (m Model) Params() any {
+	return defaults
+}
+
+// NeedsArch is true when the binary is built locally for the host.
+func (m Model) NeedsArch(server internal.Server) bool {
+	return server.BuildLocal
+}
+
+// source returns the repository at the configured ref.
+func (m Model) source(server internal.Server) executor.Source {
+	src := source
+	src.Ref = executor.Override(defaults, server.Params).Ref
+	return src
+}
+
+// unitFile returns the systemd unit.
+func (m Model) unitFile(server internal.Server) executor.File {
+	p := executor.Override(defaults, server.Params)
+
+	return executor.File{
+		Path: "/etc/systemd/system/alerthistory.service",
+		Content: executor.Render(`[Unit]
 Description=Alerthistory Service
 After=network.target
 StartLimitIntervalSec=0
@@ -97,18 +110,10 @@ Restart=always
 RestartSec=1
 User=root
 WorkingDirectory=/opt/alerthistory
-ExecStart=/opt/alerthistory/alerthistoryserver --port=8082 --db-path=/var/lib/alerthistory/alerthistory.db
+ExecStart=/opt/alerthistory/alerthistoryserver --port={{.Port}} --db-path=/var/lib/alerthistory/alerthistory.db
 
 [Install]
 WantedBy=multi-user.target
-EOF'`
-}
-
-func gitCloneCommand(s internal.Server, repo string) string {
-	return fmt.Sprintf(
-		"cd /opt && git clone https://%s:%s@github.com/%s.git",
-		s.GHUser,
-		s.GHPass,
-		repo,
-	)
+`, p),
+	}
 }
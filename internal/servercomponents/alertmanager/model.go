@@ -1,93 +1,118 @@
 package alertmanager
 
 import (
-	"fmt"
-	"log"
-	"strings"
-	"time"
-
 	"github.com/elsgaard/firstmate/internal"
-	"github.com/sfreiberg/simplessh"
+	"github.com/elsgaard/firstmate/internal/servercomponents/executor"
 )
 
 type Model struct{}
 
-func (m Model) Deploy(server internal.Server) error {
-	log.Printf("▶ Starting alertmanager deploy on %s", server.FQDN)
-	return m.executeRemoteCommands(server, m.getInstallCommands())
+// Params select the release and the settings rendered into the unit and
+// configuration files.
+type Params struct {
+	Version       string `param:"version"`
+	SHA256        string `param:"sha256"` // pins the archive checksum instead of trusting sha256sums.txt
+	ExternalURL   string `param:"external_url"`
+	SMTPSmarthost string `param:"smtp_smarthost"`
+	SMTPFrom      string `param:"smtp_from"`
+	ServiceDesk   string `param:"servicedesk"`
 }
 
-func (m Model) Update(server internal.Server) error {
-	log.Printf("▶ Starting alertmanager update on %s", server.FQDN)
-	return m.executeRemoteCommands(server, m.getUpdateCommands())
+// defaults apply to anything not overridden with --set or the inventory.
+var defaults = Params{
+	Version:       "0.28.1",
+	ExternalURL:   "https://alertmanager.b2bi.dk",
+	SMTPSmarthost: "smtp.b2bi.dk:25",
+	SMTPFrom:      "alertmanager@truecommerce.com",
+	ServiceDesk:   "servicedesk@truecommerce.com",
 }
 
-// executeRemoteCommands centralizes connection, execution, and error handling.
-func (m Model) executeRemoteCommands(server internal.Server, cmds []string) error {
-	client, err := simplessh.ConnectWithPassword(server.FQDN, server.User, server.Pass)
-	if err != nil {
-		return fmt.Errorf("SSH connection failed: %w", err)
-	}
-	defer client.Close()
-
-	for _, cmd := range cmds {
-		serverCmd := m.checkCustomAction(cmd)
-
-		log.Printf("→ Executing: %s", serverCmd)
-		out, err := client.Exec(serverCmd)
-		if err != nil {
-			log.Printf("⚠️ Command failed: %v", err)
-			continue // `return err` to fail-fast, for now just continue
-		}
-		if len(out) > 0 {
-			log.Printf("→ Output: %s", strings.TrimSpace(string(out)))
-		}
-
-		time.Sleep(500 * time.Millisecond) // gentle pacing between commands
-	}
+func (m Model) UpdateSteps(server internal.Server) []executor.Step {
+	return append(executor.InstallRelease(m.release(server)),
+		executor.WriteFile(m.configFile(server)),
+		executor.WriteFile(m.unitFile(server)),
+		executor.DaemonReload("alertmanager.service"),
+		executor.Cmd("systemctl restart alertmanager"),
+	)
+}
 
-	log.Println("✅ alertmanager operation completed successfully")
-	return nil
+func (m Model) InstallSteps(server internal.Server) []executor.Step {
+	return append(executor.InstallRelease(m.release(server)),
+		executor.Cmd("useradd -M -r -s /bin/false alertmanager").Unless(executor.UserExists("alertmanager")),
+		executor.Cmd("mkdir -p /etc/alertmanager").Unless(executor.DirExists("/etc/alertmanager")),
+		executor.WriteFile(m.configFile(server)),
+		executor.Cmd("mkdir -p /var/lib/alertmanager").Unless(executor.DirExists("/var/lib/alertmanager")),
+		executor.Cmd("chown -R alertmanager:alertmanager /var/lib/alertmanager /etc/alertmanager").Unless(executor.OwnedBy("alertmanager", "/var/lib/alertmanager", "/etc/alertmanager")),
+		executor.WriteFile(m.unitFile(server)),
+		executor.DaemonReload("alertmanager.service"),
+		executor.EnableNow("alertmanager.service"),
+	)
 }
 
-func (m Model) getUpdateCommands() []string {
-	return []string{
-		"systemctl daemon-reload",
-		"systemctl restart alertmanager",
-	}
+func (m Model) RollbackSteps(server internal.Server) []executor.Step {
+	return append(executor.RollbackRelease(m.release(server)),
+		executor.WriteFile(m.configFile(server)),
+		executor.WriteFile(m.unitFile(server)),
+		executor.DaemonReload("alertmanager.service"),
+		executor.Cmd("systemctl restart alertmanager"),
+	)
 }
 
-func (m Model) getInstallCommands() []string {
-	return []string{
-		"wget -q https://github.com/prometheus/alertmanager/releases/download/v0.28.1/alertmanager-0.28.1.linux-amd64.tar.gz",
-		"tar -xvzf alertmanager-0.28.1.linux-amd64.tar.gz",
-		"cd alertmanager-0.28.1.linux-amd64 && mv alertmanager amtool /usr/local/bin/",
-		"mkdir -p /etc/alertmanager",
-		"CUSTOM: CreateConfigFile",
-		"mkdir -p /var/lib/alertmanager",
-		"useradd -M -r -s /bin/false alertmanager",
-		"chown -R alertmanager:alertmanager /var/lib/alertmanager /etc/alertmanager",
-		"CUSTOM: CreateUnitFile",
-		"systemctl daemon-reload",
-		"systemctl enable --now alertmanager",
+func (m Model) UninstallSteps(server internal.Server, purge bool) []executor.Step {
+	steps := append(executor.RemoveUnit("alertmanager.service"),
+		executor.RemoveRelease(m.release(server)),
+	)
+	if purge {
+		steps = append(steps,
+			executor.Cmd("rm -rf /etc/alertmanager /var/lib/alertmanager"),
+			executor.Cmd("userdel alertmanager").IgnoreErrors(),
+		)
 	}
+	return steps
 }
 
-// Custom action dispatcher.
-func (m Model) checkCustomAction(action string) string {
-	if strings.HasSuffix(action, "CreateUnitFile") {
-		return m.createUnitFile()
+func (m Model) Service(server internal.Server) executor.Service {
+	return executor.Service{
+		Unit:    "alertmanager.service",
+		Files:   []executor.File{m.unitFile(server), m.configFile(server)},
+		Version: "/usr/local/bin/alertmanager --version 2>&1",
+		Port:    9093,
+		Health:  "/-/healthy",
 	}
-	if strings.HasSuffix(action, "CreateConfigFile") {
-		return m.createConfigFile()
+}
+
+func (m Model) Params() any {
+	return defaults
+}
+
+// NeedsArch is true: the release archive is built per architecture.
+func (m Model) NeedsArch(server internal.Server) bool {
+	return true
+}
+
+// release returns the upstream tarball for the configured version and the
+// host's architecture.
+func (m Model) release(server internal.Server) executor.Release {
+	p := executor.Override(defaults, server.Params)
+
+	return executor.Release{
+		Repo:     "prometheus/alertmanager",
+		Name:     "alertmanager",
+		Version:  p.Version,
+		Arch:     server.Arch,
+		Push:     server.Push,
+		SHA256:   p.SHA256,
+		Binaries: []string{"alertmanager", "amtool"},
 	}
-	return action
 }
 
-// CreateUnitFile returns a properly escaped heredoc for remote cat.
-func (m Model) createUnitFile() string {
-	return `sudo bash -c 'cat > /etc/systemd/system/alertmanager.service <<EOF
-[Unit]
+// unitFile returns the systemd unit.
+func (m Model) unitFile(server internal.Server) executor.File {
+	p := executor.Override(defaults, server.Params)
+
+	return executor.File{
+		Path: "/etc/systemd/system/alertmanager.service",
+		Content: executor.Render(`[Unit]
 Description=Prometheus Alertmanager
 Wants=network-online.target
 After=network-online.target
@@ -99,23 +124,28 @@ Type=simple
 ExecStart=/usr/local/bin/alertmanager \
 --config.file=/etc/alertmanager/alertmanager.yml \
 --storage.path=/var/lib/alertmanager \
---web.external-url=https://alertmanager.b2bi.dk
+--web.external-url={{.ExternalURL}}
 
 Restart=on-failure
 
 [Install]
 WantedBy=multi-user.target
-EOF'`
+`, p),
+	}
 }
 
-// CreateConfigFile returns a properly escaped heredoc for remote cat.
-func (m Model) createConfigFile() string {
-	return `sudo bash -c 'cat > /etc/alertmanager/alertmanager.yml <<EOF
-global:
+// configFile returns the main configuration file.
+func (m Model) configFile(server internal.Server) executor.File {
+	p := executor.Override(defaults, server.Params)
+
+	return executor.File{
+		Path:  "/etc/alertmanager/alertmanager.yml",
+		Owner: "alertmanager",
+		Content: executor.Render(`global:
   pagerduty_url: 'https://events.pagerduty.com/v2/enqueue'
   smtp_require_tls: false
-  smtp_smarthost: 'smtp.b2bi.dk:25'
-  smtp_from: 'alertmanager@truecommerce.com'          
+  smtp_smarthost: '{{.SMTPSmarthost}}'
+  smtp_from: '{{.SMTPFrom}}'          
 
 route:
   group_by: ['alertname','instance']
@@ -140,11 +170,12 @@ route:
 receivers:
 - name: netsuite_servicedesk
   email_configs:
-   - to: 'servicedesk@truecommerce.com'
+   - to: '{{.ServiceDesk}}'
   webhook_configs:
 
 - name: default
 
 inhibit_rules:
-EOF'`
+`, p),
+	}
 }
@@ -3,9 +3,28 @@ package servercomponents
 
 import (
 	"github.com/elsgaard/firstmate/internal"
+	"github.com/elsgaard/firstmate/internal/servercomponents/executor"
 )
 
 type Component interface {
-	Deploy(server internal.Server) error
-	Update(server internal.Server) error
+	// InstallSteps, UpdateSteps, UninstallSteps and RollbackSteps return the
+	// steps that install, update, uninstall or roll back the component. purge
+	// also deletes its configuration and data; rollback restores the release
+	// that ran before the last update.
+	InstallSteps(server internal.Server) []executor.Step
+	UpdateSteps(server internal.Server) []executor.Step
+	UninstallSteps(server internal.Server, purge bool) []executor.Step
+	RollbackSteps(server internal.Server) []executor.Step
+
+	// Service describes the unit, files, version and port the component
+	// leaves on the host.
+	Service(server internal.Server) executor.Service
+
+	// Params returns the default parameters rendered into the component's
+	// files, or nil if it has none.
+	Params() any
+
+	// NeedsArch reports whether the steps for server depend on the host's
+	// architecture, which is then detected before they run.
+	NeedsArch(server internal.Server) bool
 }
@@ -0,0 +1,47 @@
+// internal/servercomponents/executor/arch.go
+package executor
+
+import (
+	"fmt"
+	"strings"
+)
+
+// archNames maps dpkg and uname -m architectures to the names used by
+// release archives.
+var archNames = map[string]string{
+	"amd64":   "amd64",
+	"x86_64":  "amd64",
+	"arm64":   "arm64",
+	"aarch64": "arm64",
+	"armhf":   "armv7",
+	"armv7l":  "armv7",
+	"armv7":   "armv7",
+	"i386":    "386",
+	"i686":    "386",
+	"386":     "386",
+}
+
+// Arch returns the host's architecture as named in release archives, e.g.
+// amd64 or arm64.
+func (s *Session) Arch() (string, error) {
+	client, err := s.connect()
+	if err != nil {
+		return "", err
+	}
+
+	out, err := client.Exec("dpkg --print-architecture 2>/dev/null || uname -m")
+	if err != nil {
+		return "", fmt.Errorf("detect architecture: %w", err)
+	}
+	return NormalizeArch(strings.TrimSpace(string(out)))
+}
+
+// NormalizeArch maps a dpkg or uname -m architecture, or a release archive
+// name, to the name used by release archives.
+func NormalizeArch(name string) (string, error) {
+	arch, ok := archNames[name]
+	if !ok {
+		return "", fmt.Errorf("unsupported architecture %q", name)
+	}
+	return arch, nil
+}
@@ -0,0 +1,50 @@
+// internal/servercomponents/executor/backup.go
+package executor
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/elsgaard/firstmate/internal"
+)
+
+// BackupDir is where database backups are kept on the host. It lies
+// outside /var/lib so that uninstall --purge leaves the backups alone.
+const BackupDir = "/var/backups/firstmate"
+
+// Download copies a file from the host to the operator machine.
+type Download struct {
+	Remote string
+	Local  string
+}
+
+// BackupDB returns the steps that take a consistent copy of the SQLite
+// database db with sqlite3 .backup, keeping the newest keep copies on the
+// host. With server.BackupDir set the copy is also downloaded there. The
+// steps are skipped while the database does not exist yet.
+func BackupDB(server internal.Server, db string, keep int) []Step {
+	name := strings.TrimSuffix(path.Base(db), path.Ext(db))
+	dir := BackupDir + "/" + name
+	latest := dir + "/latest.db"
+	missing := "! " + PathExists(db)
+
+	steps := []Step{
+		Custom("back up "+db, fmt.Sprintf(
+			`mkdir -p %[1]s && f=%[1]s/%[2]s-$(date +%%Y%%m%%dT%%H%%M%%S).db && sqlite3 %[3]s ".backup '$f'" && ln -sfn "$f" %[4]s && `+
+				`ls -1t %[1]s/%[2]s-*.db | tail -n +%[5]d | xargs -r rm -f`,
+			dir, name, db, latest, max(keep, 1)+1)).Unless(missing),
+	}
+
+	if server.BackupDir != "" {
+		local := filepath.Join(server.BackupDir, server.FQDN, name+"-"+time.Now().Format("20060102T150405")+".db")
+		steps = append(steps, Step{
+			Name:     "download " + latest,
+			Download: &Download{Remote: latest, Local: local},
+			ReadOnly: true,
+		}.Unless(missing))
+	}
+	return steps
+}
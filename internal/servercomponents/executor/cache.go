@@ -0,0 +1,131 @@
+// internal/servercomponents/executor/cache.go
+package executor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// PushDir is where pushed artifacts are uploaded on the host.
+const PushDir = "/var/cache/firstmate"
+
+// Artifact is a file prepared on the operator machine, kept in the local
+// cache and uploaded to hosts that cannot fetch it themselves.
+type Artifact struct {
+	Name    string                 // file name in the cache and in PushDir
+	Fetch   func(dst string) error // writes the artifact to dst
+	Refresh bool                   // fetch once per run instead of reusing the cache
+}
+
+// Push returns a step that uploads a to PushDir on the host and then runs
+// cmd, if any.
+func Push(a Artifact, cmd string) Step {
+	return Step{Name: "push " + a.Name, Cmd: cmd, Push: &a}
+}
+
+// HostPath returns where a is uploaded on the host.
+func (a Artifact) HostPath() string {
+	return PushDir + "/" + a.Name
+}
+
+// CacheDir returns the operator's artifact cache, ~/.cache/firstmate unless
+// XDG_CACHE_HOME points elsewhere.
+func CacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "firstmate")
+}
+
+// fetched remembers artifacts already prepared by this run, so that hosts
+// processed in parallel share a single download or build.
+var (
+	fetchedMu sync.Mutex
+	fetched   = map[string]*fetchResult{}
+)
+
+type fetchResult struct {
+	once sync.Once
+	path string
+	err  error
+}
+
+// Local returns the path of a in the cache, fetching it on first use.
+func (a Artifact) Local() (string, error) {
+	fetchedMu.Lock()
+	res, ok := fetched[a.Name]
+	if !ok {
+		res = &fetchResult{}
+		fetched[a.Name] = res
+	}
+	fetchedMu.Unlock()
+
+	res.once.Do(func() {
+		res.path = filepath.Join(CacheDir(), a.Name)
+		if !a.Refresh {
+			if _, err := os.Stat(res.path); err == nil {
+				return
+			}
+		}
+		res.err = a.fetch(res.path)
+	})
+	return res.path, res.err
+}
+
+func (a Artifact) fetch(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	tmp := fmt.Sprintf("%s.%d.tmp", path, os.Getpid())
+	if err := a.Fetch(tmp); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("fetch %s: %w", a.Name, err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// download saves the body of url to dst.
+func download(url, dst string) error {
+	resp, err := http.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, resp.Body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// fileSHA256 returns the hex SHA-256 of the file at path.
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
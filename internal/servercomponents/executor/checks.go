@@ -0,0 +1,80 @@
+// internal/servercomponents/executor/checks.go
+package executor
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+)
+
+// The functions below build Step.Check commands for common desired states.
+
+// PathExists succeeds when path exists.
+func PathExists(path string) string {
+	return "test -e " + path
+}
+
+// DirExists succeeds when path is a directory.
+func DirExists(path string) string {
+	return "test -d " + path
+}
+
+// FileMatches succeeds when the file on the host holds exactly f's content
+// with f's owner and mode.
+func FileMatches(f File) string {
+	sum := sha256.Sum256([]byte(f.Data()))
+	return fmt.Sprintf(`echo '%x  %s' | sha256sum --check --status && [ "$(stat -c '%%U %%a' %[2]s)" = '%s %o' ]`,
+		sum, f.Path, f.owner(), f.mode())
+}
+
+// HasMode succeeds when path has the given octal permissions, e.g. "755".
+func HasMode(path, mode string) string {
+	return fmt.Sprintf(`[ "$(stat -c %%a %s)" = %s ]`, path, mode)
+}
+
+// OwnedBy succeeds when every file under paths belongs to user and the
+// group of the same name.
+func OwnedBy(user string, paths ...string) string {
+	return fmt.Sprintf(`[ -z "$(find %s \( ! -user %s -o ! -group %s \) -print -quit)" ]`,
+		strings.Join(paths, " "), user, user)
+}
+
+// UserExists succeeds when the system user exists.
+func UserExists(name string) string {
+	return "id -u " + name + " >/dev/null 2>&1"
+}
+
+// BinaryVersion succeeds when binary --version reports version.
+func BinaryVersion(binary, version string) string {
+	return fmt.Sprintf("%s --version 2>&1 | grep -qF 'version %s '", binary, version)
+}
+
+// RepoPresent succeeds when repo is checked out under /opt.
+func RepoPresent(repo string) string {
+	return DirExists(RepoDir(repo) + "/.git")
+}
+
+// UnitRunning succeeds when unit is both enabled and active.
+func UnitRunning(unit string) string {
+	return "systemctl is-enabled --quiet " + unit + " && systemctl is-active --quiet " + unit
+}
+
+// Listening succeeds when something listens on the TCP port.
+func Listening(port int) string {
+	return fmt.Sprintf("ss -Hltn 'sport = :%d' | grep -q .", port)
+}
+
+// UnitMasked succeeds when unit is masked.
+func UnitMasked(unit string) string {
+	return "systemctl is-enabled " + unit + " 2>/dev/null | grep -qx masked"
+}
+
+// SymlinkTo succeeds when link is a symlink pointing at target.
+func SymlinkTo(link, target string) string {
+	return fmt.Sprintf(`[ "$(readlink %s)" = %s ]`, link, target)
+}
+
+// PackagesInstalled succeeds when every Debian package is installed.
+func PackagesInstalled(pkgs ...string) string {
+	return "dpkg -s " + strings.Join(pkgs, " ") + " >/dev/null 2>&1"
+}
@@ -0,0 +1,117 @@
+// internal/servercomponents/executor/connect.go
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+
+	"github.com/elsgaard/firstmate/internal"
+	"github.com/sfreiberg/simplessh"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// Connect opens an SSH connection to server. Authentication is tried with the
+// identity file, then the ssh-agent behind SSH_AUTH_SOCK and finally the
+// password; methods that are not configured are skipped. The host key must
+// match known_hosts before any authentication takes place.
+func Connect(server internal.Server) (*simplessh.Client, error) {
+	agentConn := dialAgent()
+	if agentConn != nil {
+		// The agent is only needed during authentication inside ssh.Dial.
+		defer agentConn.Close()
+	}
+
+	auth, err := authMethods(server, agentConn)
+	if err != nil {
+		return nil, err
+	}
+
+	addr := hostPort(server.FQDN)
+	hostKeyCallback, algorithms, err := hostKeyCheck(server, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &ssh.ClientConfig{
+		User:              server.User,
+		Auth:              auth,
+		HostKeyCallback:   hostKeyCallback,
+		HostKeyAlgorithms: algorithms,
+		Timeout:           simplessh.DefaultTimeout,
+	}
+
+	client, err := ssh.Dial("tcp", addr, config)
+	if err != nil {
+		return nil, err
+	}
+	return &simplessh.Client{SSHClient: client}, nil
+}
+
+func authMethods(server internal.Server, agentConn net.Conn) ([]ssh.AuthMethod, error) {
+	var methods []ssh.AuthMethod
+
+	if server.IdentityFile != "" {
+		signer, err := loadIdentity(server.IdentityFile)
+		if err != nil {
+			return nil, err
+		}
+		methods = append(methods, ssh.PublicKeys(signer))
+	}
+
+	if agentConn != nil {
+		methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(agentConn).Signers))
+	}
+
+	if server.Pass != "" {
+		methods = append(methods, ssh.Password(server.Pass))
+	}
+
+	if len(methods) == 0 {
+		return nil, errors.New("no SSH authentication method available: set --identity-file, SSH_AUTH_SOCK or --pass")
+	}
+	return methods, nil
+}
+
+// dialAgent connects to the ssh-agent behind SSH_AUTH_SOCK. It returns nil
+// when no agent is configured or it cannot be reached.
+func dialAgent() net.Conn {
+	sock := os.Getenv("SSH_AUTH_SOCK")
+	if sock == "" {
+		return nil
+	}
+	conn, err := net.Dial("unix", sock)
+	if err != nil {
+		log.Printf("⚠️ ssh-agent unavailable: %v", err)
+		return nil
+	}
+	return conn
+}
+
+// loadIdentity reads a private key, using SSH_KEY_PASSPHRASE for encrypted keys.
+func loadIdentity(path string) (ssh.Signer, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read identity file: %w", err)
+	}
+
+	signer, err := ssh.ParsePrivateKey(pem)
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(pem, []byte(os.Getenv("SSH_KEY_PASSPHRASE")))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse identity file %s: %w", path, err)
+	}
+	return signer, nil
+}
+
+func hostPort(host string) string {
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		return net.JoinHostPort(host, "22")
+	}
+	return host
+}
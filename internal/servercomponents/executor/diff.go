@@ -0,0 +1,43 @@
+// internal/servercomponents/executor/diff.go
+package executor
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/elsgaard/firstmate/internal"
+	"golang.org/x/crypto/ssh"
+)
+
+// FileDiff is the difference between a file on the host and its desired
+// content.
+type FileDiff struct {
+	Path string
+	Diff string // unified diff from the host's copy to the desired one, empty when in sync
+}
+
+// Diff connects to server and compares every file with its copy on the host.
+// A missing file is shown as added in full. The comparison runs diff(1) on
+// the host, with the desired content sent through a quoted heredoc.
+func Diff(server internal.Server, files []File) ([]FileDiff, error) {
+	client, err := Connect(server)
+	if err != nil {
+		return nil, fmt.Errorf("SSH connection failed: %w", err)
+	}
+	defer client.Close()
+
+	var diffs []FileDiff
+	for _, f := range files {
+		cmd := fmt.Sprintf(`f=%[1]s; [ -e "$f" ] || f=/dev/null; diff -u --label '%[1]s (host)' --label '%[1]s (desired)' "$f" - <<'%[2]s'`+"\n%[3]s%[2]s",
+			f.Path, heredocDelimiter, f.Data())
+
+		out, err := client.Exec(cmd)
+
+		var exit *ssh.ExitError
+		if err != nil && !(errors.As(err, &exit) && exit.ExitStatus() == 1) {
+			return nil, fmt.Errorf("diff %s: %w: %s", f.Path, err, out)
+		}
+		diffs = append(diffs, FileDiff{Path: f.Path, Diff: string(out)})
+	}
+	return diffs, nil
+}
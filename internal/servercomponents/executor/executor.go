@@ -0,0 +1,322 @@
+// internal/servercomponents/executor/executor.go
+package executor
+
+import (
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/elsgaard/firstmate/internal"
+	"github.com/sfreiberg/simplessh"
+)
+
+// Pace is the pause between two consecutive steps.
+var Pace = 500 * time.Millisecond
+
+// RetryDelay is the pause before a failed retryable step is attempted again.
+var RetryDelay = 5 * time.Second
+
+// Policy decides what happens when a step fails.
+type Policy int
+
+const (
+	Fatal     Policy = iota // abort the run (default)
+	Ignorable               // log the failure and continue
+	Retryable               // retry up to Step.Attempts times, then abort
+)
+
+// Step is a single unit of work a component wants done on the remote host.
+type Step struct {
+	Name     string    // label used in logs, defaults to Cmd
+	Cmd      string    // shell command executed on the remote host
+	Upload   *File     // file written over SFTP instead of running Cmd
+	Push     *Artifact // cached artifact uploaded before Cmd, if any
+	Download *Download // file copied to the operator machine instead of running Cmd
+	Policy   Policy    // what to do when the command fails
+	Attempts int       // total attempts for Retryable steps
+
+	// Check is a command that succeeds when the step's desired state already
+	// holds; the step is then skipped and reported as ok. It sees Env too.
+	Check string
+
+	// ReadOnly steps only report on the host and never count as a change.
+	ReadOnly bool
+
+	// AfterChange steps run regardless of Check once an earlier step of the
+	// run has changed the host, e.g. to restart a service on new files.
+	AfterChange bool
+
+	// Env holds secret environment variables for Cmd. They are sent on the
+	// session's stdin, so they never appear on a command line or in ps.
+	Env map[string]string
+}
+
+// Cmd returns a fatal step that runs cmd as given.
+func Cmd(cmd string) Step {
+	return Step{Cmd: cmd}
+}
+
+// Custom returns a named step whose command is produced by the component,
+// e.g. a rendered unit or config file.
+func Custom(name, cmd string) Step {
+	return Step{Name: name, Cmd: cmd}
+}
+
+// IgnoreErrors marks the step as allowed to fail.
+func (s Step) IgnoreErrors() Step {
+	s.Policy = Ignorable
+	return s
+}
+
+// WithRetry makes the step retry up to attempts times before aborting.
+func (s Step) WithRetry(attempts int) Step {
+	s.Policy = Retryable
+	s.Attempts = attempts
+	return s
+}
+
+// Unless skips the step when check succeeds on the host.
+func (s Step) Unless(check string) Step {
+	s.Check = check
+	return s
+}
+
+// OnChange makes the step run, even when its check holds, once an earlier
+// step of the same run has changed the host.
+func (s Step) OnChange() Step {
+	s.AfterChange = true
+	return s
+}
+
+// Observe marks the step as only reporting on the host.
+func (s Step) Observe() Step {
+	s.ReadOnly = true
+	return s
+}
+
+// WithSecretEnv exposes value to the step's command as $key without putting
+// it on the command line.
+func (s Step) WithSecretEnv(key, value string) Step {
+	env := make(map[string]string, len(s.Env)+1)
+	maps.Copy(env, s.Env)
+	env[key] = value
+	s.Env = env
+	return s
+}
+
+// Label returns the name used when logging the step.
+func (s Step) Label() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return s.Cmd
+}
+
+// StepError reports the step that aborted a run.
+type StepError struct {
+	Step string
+	Err  error
+}
+
+func (e *StepError) Error() string {
+	return fmt.Sprintf("step %q failed: %v", e.Step, e.Err)
+}
+
+func (e *StepError) Unwrap() error { return e.Err }
+
+// Result summarises a completed run.
+type Result struct {
+	Changed   int // steps that were executed on the host
+	Unchanged int // steps skipped because their desired state already held
+}
+
+// Status reports "changed" when the run touched the host and "ok" otherwise.
+func (r Result) Status() string {
+	if r.Changed > 0 {
+		return "changed"
+	}
+	return "ok"
+}
+
+// Logger returns a logger whose lines are prefixed with the host name, so
+// that output from hosts processed in parallel stays readable. The server's
+// credentials are masked in everything it writes.
+func Logger(server internal.Server) *log.Logger {
+	w := redactWriter{w: log.Writer(), secrets: server.Secrets()}
+	return log.New(w, server.FQDN+" | ", log.Flags()|log.Lmsgprefix)
+}
+
+// runner executes steps over an open connection.
+type runner struct {
+	client *simplessh.Client
+	log    *log.Logger
+}
+
+// Session is the SSH connection to one host, shared by the jobs, health
+// checks and queries firstmate runs there. It connects on first use.
+type Session struct {
+	server internal.Server
+	log    *log.Logger
+	client *simplessh.Client
+}
+
+// NewSession returns a session for server without connecting yet.
+func NewSession(server internal.Server) *Session {
+	return &Session{server: server, log: Logger(server)}
+}
+
+// connect returns the session's connection, opening it if needed.
+func (s *Session) connect() (*simplessh.Client, error) {
+	if s.client == nil {
+		client, err := Connect(s.server)
+		if err != nil {
+			return nil, fmt.Errorf("SSH connection failed: %w", err)
+		}
+		s.client = client
+	}
+	return s.client, nil
+}
+
+// Close closes the connection, if one was opened.
+func (s *Session) Close() error {
+	if s.client == nil {
+		return nil
+	}
+	return s.client.Close()
+}
+
+// Run executes the steps of job in order. It stops at the first fatal step
+// that fails and returns a *StepError naming it.
+func (s *Session) Run(job string, steps []Step) (Result, error) {
+	var res Result
+	logger := s.log
+	logger.Printf("▶ Starting %s", job)
+
+	if len(steps) == 0 {
+		logger.Printf("✅ %s has nothing to do", job)
+		return res, nil
+	}
+
+	client, err := s.connect()
+	if err != nil {
+		return res, err
+	}
+
+	r := runner{client: client, log: logger}
+	for _, step := range steps {
+		if step.Check != "" && !(step.AfterChange && res.Changed > 0) {
+			if _, err := r.shell(step.Check, step.Env); err == nil {
+				logger.Printf("✓ Already done: %s", step.Label())
+				res.Unchanged++
+				continue
+			}
+		}
+
+		err := r.runStep(step)
+		if !step.ReadOnly {
+			res.Changed++
+		}
+		if err != nil {
+			if step.Policy != Ignorable {
+				logger.Printf("❌ %s aborted", job)
+				return res, &StepError{Step: step.Label(), Err: err}
+			}
+			logger.Printf("⚠️ Ignoring failure of %q", step.Label())
+		}
+
+		time.Sleep(Pace) // gentle pacing between commands
+	}
+
+	logger.Printf("✅ %s completed successfully (%d changed, %d already done)", job, res.Changed, res.Unchanged)
+	return res, nil
+}
+
+// runStep executes a single step, honouring its retry policy.
+func (r runner) runStep(step Step) error {
+	attempts := 1
+	if step.Policy == Retryable && step.Attempts > 1 {
+		attempts = step.Attempts
+	}
+
+	var err error
+	for i := 1; i <= attempts; i++ {
+		if i > 1 {
+			r.log.Printf("↻ Retrying (%d/%d): %s", i, attempts, step.Label())
+			time.Sleep(RetryDelay)
+		} else {
+			r.log.Printf("→ Executing: %s", step.Label())
+		}
+
+		var out []byte
+		out, err = r.exec(step)
+		if len(out) > 0 {
+			r.log.Printf("→ Output: %s", strings.TrimSpace(string(out)))
+		}
+		if err == nil {
+			return nil
+		}
+		r.log.Printf("⚠️ Command failed: %v", err)
+	}
+	return err
+}
+
+// exec runs the step's command, feeding its secret environment on stdin.
+func (r runner) exec(step Step) ([]byte, error) {
+	if step.Upload != nil {
+		return nil, r.upload(*step.Upload)
+	}
+	if step.Download != nil {
+		return nil, r.download(*step.Download)
+	}
+	if step.Push != nil {
+		if err := r.push(*step.Push); err != nil {
+			return nil, err
+		}
+		if step.Cmd == "" {
+			return nil, nil
+		}
+	}
+	return r.shell(step.Cmd, step.Env)
+}
+
+// shell runs cmd on the host, feeding env on stdin.
+func (r runner) shell(cmd string, env map[string]string) ([]byte, error) {
+	if len(env) == 0 {
+		return r.client.Exec(cmd)
+	}
+
+	prelude, stdin, err := secretPrelude(env)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := r.client.SSHClient.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	session.Stdin = strings.NewReader(stdin)
+	return session.CombinedOutput(prelude + cmd)
+}
+
+// secretPrelude returns the shell code that reads env from stdin and exports
+// it, followed by the stdin that feeds it, one value per line.
+func secretPrelude(env map[string]string) (prelude, stdin string, err error) {
+	keys := slices.Sorted(maps.Keys(env))
+
+	var p, in strings.Builder
+	for _, k := range keys {
+		v := env[k]
+		if strings.ContainsAny(v, "\r\n") {
+			return "", "", fmt.Errorf("secret %s contains a line break", k)
+		}
+		fmt.Fprintf(&p, "IFS= read -r %s; ", k)
+		in.WriteString(v + "\n")
+	}
+	fmt.Fprintf(&p, "export %s; ", strings.Join(keys, " "))
+	return p.String(), in.String(), nil
+}
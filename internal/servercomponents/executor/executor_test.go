@@ -0,0 +1,78 @@
+package executor
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestSecretPrelude(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		cmd  string
+		want string
+	}{
+		{
+			name: "single value",
+			env:  map[string]string{"PASS": "hunter2"},
+			cmd:  `printf '%s' "$PASS"`,
+			want: "hunter2",
+		},
+		{
+			name: "exported to child processes",
+			env:  map[string]string{"PASS": "hunter2"},
+			cmd:  `sh -c 'printf "%s" "$PASS"'`,
+			want: "hunter2",
+		},
+		{
+			name: "several values in any order",
+			env:  map[string]string{"USER": "bob", "PASS": "hunter2", "A": "first"},
+			cmd:  `printf '%s|%s|%s' "$A" "$USER" "$PASS"`,
+			want: "first|bob|hunter2",
+		},
+		{
+			name: "whitespace, quotes and backslashes kept",
+			env:  map[string]string{"PASS": ` a\b 'c' "d" $e; ` + "\t"},
+			cmd:  `printf '%s' "$PASS"`,
+			want: ` a\b 'c' "d" $e; ` + "\t",
+		},
+		{
+			name: "empty value",
+			env:  map[string]string{"PASS": ""},
+			cmd:  `printf '[%s]' "$PASS"`,
+			want: "[]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prelude, stdin, err := secretPrelude(tt.env)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for k, v := range tt.env {
+				if v != "" && strings.Contains(prelude+tt.cmd, v) {
+					t.Errorf("secret %s appears on the command line: %q", k, prelude)
+				}
+			}
+
+			sh := exec.Command("sh", "-c", prelude+tt.cmd)
+			sh.Stdin = strings.NewReader(stdin)
+			out, err := sh.CombinedOutput()
+			if err != nil {
+				t.Fatalf("%v: %s", err, out)
+			}
+			if string(out) != tt.want {
+				t.Errorf("output = %q, want %q", out, tt.want)
+			}
+		})
+	}
+}
+
+func TestSecretPreludeRejectsLineBreaks(t *testing.T) {
+	for _, v := range []string{"a\nb", "a\rb", "a\n"} {
+		if _, _, err := secretPrelude(map[string]string{"PASS": v}); err == nil {
+			t.Errorf("secretPrelude accepted %q", v)
+		}
+	}
+}
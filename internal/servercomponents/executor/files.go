@@ -0,0 +1,66 @@
+// internal/servercomponents/executor/files.go
+package executor
+
+import (
+	"io/fs"
+	"strings"
+)
+
+// heredocDelimiter terminates file content sent through a heredoc.
+const heredocDelimiter = "FIRSTMATE_EOF"
+
+// File is the desired content of a file on the host.
+type File struct {
+	Path    string
+	Content string
+	Owner   string      // owning user, also used as group; defaults to root
+	Mode    fs.FileMode // permissions; defaults to 0644
+}
+
+// owner returns the file's owner, applying the default.
+func (f File) owner() string {
+	if f.Owner == "" {
+		return "root"
+	}
+	return f.Owner
+}
+
+// mode returns the file's permissions, applying the default.
+func (f File) mode() fs.FileMode {
+	if f.Mode == 0 {
+		return 0o644
+	}
+	return f.Mode
+}
+
+// Data returns the exact bytes the file should hold; a trailing newline is
+// added when Content lacks one.
+func (f File) Data() string {
+	if strings.HasSuffix(f.Content, "\n") {
+		return f.Content
+	}
+	return f.Content + "\n"
+}
+
+// WriteFile returns a step uploading f over SFTP, skipped when the host
+// already has that content, owner and mode. The bytes go to a temporary file
+// next to the target that is renamed into place, so no shell quoting is
+// involved and readers never see a partial file.
+func WriteFile(f File) Step {
+	f.Owner, f.Mode = f.owner(), f.mode()
+	return Step{
+		Name:   "upload " + f.Path,
+		Upload: &f,
+		Check:  FileMatches(f),
+	}
+}
+
+// Service describes what a component leaves on the host, so that its live
+// state can be inspected.
+type Service struct {
+	Unit    string // systemd unit, empty if the component has none
+	Files   []File // files the component renders
+	Version string // command printing the installed version
+	Port    int    // TCP port the service listens on, 0 if none
+	Health  string // HTTP path on Port answering 2xx when healthy, e.g. /-/ready
+}
@@ -0,0 +1,181 @@
+// internal/servercomponents/executor/git.go
+package executor
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/elsgaard/firstmate/internal"
+)
+
+// githubKnownHosts pins github.com's published host key for SSH clones.
+const githubKnownHosts = "github.com ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9GKJl"
+
+// noCredentialHelpers clears credential.helper for the git commands that
+// follow, so a configured "store" helper cannot persist the credentials.
+const noCredentialHelpers = `export GIT_CONFIG_COUNT=1 GIT_CONFIG_KEY_0=credential.helper GIT_CONFIG_VALUE_0= && `
+
+// GitClone returns a step cloning the GitHub repository repo ("owner/name")
+// into /opt/<name>, skipped when the checkout already exists. With
+// server.Push the clone is made from a bundle pushed from the operator.
+func GitClone(server internal.Server, repo string) Step {
+	if server.Push {
+		b := bundle(server, repo)
+		return Push(b, "git -C /opt clone "+b.HostPath()).Unless(RepoPresent(repo))
+	}
+	return gitStep(server, "git clone "+repo, repo,
+		"git -C /opt clone "+remoteURL(server, repo)).Unless(RepoPresent(repo))
+}
+
+// GitFetch returns a step fetching repo in its /opt checkout with the given
+// extra arguments. The origin URL is reset first, which also scrubs tokens
+// that older releases embedded in .git/config. With server.Push branches
+// and tags are fetched from a pushed bundle into origin's refs instead.
+func GitFetch(server internal.Server, repo string, args ...string) Step {
+	dir := RepoDir(repo)
+	if server.Push {
+		b := bundle(server, repo)
+		return Push(b, "git -C "+dir+" fetch --prune "+b.HostPath()+" '+refs/heads/*:refs/remotes/origin/*' '+refs/tags/*:refs/tags/*'")
+	}
+	fetch := append([]string{"git -C", dir, "fetch"}, args...)
+	return gitStep(server, "git fetch "+repo, repo,
+		"git -C "+dir+" remote set-url origin "+remoteURL(server, repo)+" && "+strings.Join(fetch, " "))
+}
+
+// GitVersion returns a command describing the checked-out revision of repo.
+// The commit is always included, even when it sits exactly on a tag.
+func GitVersion(repo string) string {
+	return "git -C " + RepoDir(repo) + " describe --tags --always --long --dirty"
+}
+
+// RepoDir returns the checkout directory of repo on the host.
+func RepoDir(repo string) string {
+	return "/opt/" + path.Base(repo)
+}
+
+// gitStep wraps a git command with short-lived GitHub credentials. With an
+// SSH key the key is written to a private temporary directory; otherwise a
+// GIT_ASKPASS helper answers with the token. Either way the secrets arrive
+// on stdin, credential helpers are bypassed and the temporary directory is
+// removed when the command exits, so nothing is left on the host.
+func gitStep(server internal.Server, name, repo, git string) Step {
+	if server.GHKey != "" {
+		return Step{
+			Name: name,
+			Cmd: `t=$(mktemp -d) && trap 'rm -rf "$t"' EXIT && ` +
+				`printf '%s' "$GH_KEY" | base64 -d > "$t/key" && chmod 600 "$t/key" && ` +
+				`echo '` + githubKnownHosts + `' > "$t/known_hosts" && ` +
+				`export GIT_SSH_COMMAND="ssh -i $t/key -o IdentitiesOnly=yes -o StrictHostKeyChecking=yes -o UserKnownHostsFile=$t/known_hosts" && ` +
+				noCredentialHelpers +
+				git,
+		}.WithSecretEnv("GH_KEY", base64.StdEncoding.EncodeToString([]byte(server.GHKey)))
+	}
+
+	return Step{
+		Name: name,
+		Cmd: `t=$(mktemp -d) && trap 'rm -rf "$t"' EXIT && ` +
+			`printf '#!/bin/sh\ncase "$1" in Username*) echo "$GH_USER" ;; *) echo "$GH_PASS" ;; esac\n' > "$t/askpass" && chmod 700 "$t/askpass" && ` +
+			`export GIT_ASKPASS="$t/askpass" GIT_TERMINAL_PROMPT=0 && ` +
+			noCredentialHelpers +
+			git,
+	}.WithSecretEnv("GH_USER", server.GHUser).WithSecretEnv("GH_PASS", server.GHPass)
+}
+
+// bundle returns a git bundle of repo's branches and tags, built once per run
+// from the operator's mirror.
+func bundle(server internal.Server, repo string) Artifact {
+	return Artifact{
+		Name:    path.Base(repo) + ".bundle",
+		Refresh: true,
+		Fetch: func(dst string) error {
+			mirror, err := syncMirror(server, repo)
+			if err != nil {
+				return err
+			}
+			return localGit(server, "-C", mirror, "bundle", "create", dst, "--all")
+		},
+	}
+}
+
+// mirrors remembers the mirrors synced by this run. Artifacts built from the
+// same repository, e.g. for different architectures, share one sync instead
+// of cloning into the same directory concurrently.
+var (
+	mirrorsMu sync.Mutex
+	mirrors   = map[string]*fetchResult{}
+)
+
+// syncMirror brings the bare mirror of repo in the operator's cache up to
+// date with GitHub, once per run, and returns its path.
+func syncMirror(server internal.Server, repo string) (string, error) {
+	mirrorsMu.Lock()
+	res, ok := mirrors[repo]
+	if !ok {
+		res = &fetchResult{}
+		mirrors[repo] = res
+	}
+	mirrorsMu.Unlock()
+
+	res.once.Do(func() {
+		res.path = filepath.Join(CacheDir(), "git", path.Base(repo)+".git")
+		url := remoteURL(server, repo)
+
+		if _, err := os.Stat(res.path); err != nil {
+			res.err = localGit(server, "clone", "--quiet", "--bare", url, res.path)
+		} else {
+			res.err = localGit(server, "-C", res.path, "fetch", "--quiet", "--prune", "--force", url,
+				"+refs/heads/*:refs/heads/*", "+refs/tags/*:refs/tags/*")
+		}
+	})
+	return res.path, res.err
+}
+
+// localGit runs git on the operator machine with the same short-lived
+// GitHub credentials gitStep hands to hosts.
+func localGit(server internal.Server, args ...string) error {
+	tmp, err := os.MkdirTemp("", "firstmate-git-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	env := append(os.Environ(), "GIT_CONFIG_COUNT=1", "GIT_CONFIG_KEY_0=credential.helper", "GIT_CONFIG_VALUE_0=")
+	switch {
+	case server.GHKey != "":
+		key, hosts := filepath.Join(tmp, "key"), filepath.Join(tmp, "known_hosts")
+		if err := os.WriteFile(key, []byte(server.GHKey), 0o600); err != nil {
+			return err
+		}
+		if err := os.WriteFile(hosts, []byte(githubKnownHosts+"\n"), 0o600); err != nil {
+			return err
+		}
+		env = append(env, "GIT_SSH_COMMAND=ssh -i "+key+" -o IdentitiesOnly=yes -o StrictHostKeyChecking=yes -o UserKnownHostsFile="+hosts)
+	case server.GHPass != "":
+		askpass := filepath.Join(tmp, "askpass")
+		script := "#!/bin/sh\ncase \"$1\" in Username*) echo \"$GH_USER\" ;; *) echo \"$GH_PASS\" ;; esac\n"
+		if err := os.WriteFile(askpass, []byte(script), 0o700); err != nil {
+			return err
+		}
+		env = append(env, "GIT_ASKPASS="+askpass, "GIT_TERMINAL_PROMPT=0", "GH_USER="+server.GHUser, "GH_PASS="+server.GHPass)
+	}
+
+	cmd := exec.Command("git", args...)
+	cmd.Env = env
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git %s: %v: %s", args[0], err, Redact(strings.TrimSpace(string(out)), server.Secrets()...))
+	}
+	return nil
+}
+
+func remoteURL(server internal.Server, repo string) string {
+	if server.GHKey != "" {
+		return "git@github.com:" + repo + ".git"
+	}
+	return "https://github.com/" + repo + ".git"
+}
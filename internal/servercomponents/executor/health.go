@@ -0,0 +1,74 @@
+// internal/servercomponents/executor/health.go
+package executor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HealthInterval is the pause between two health probes.
+var HealthInterval = 2 * time.Second
+
+// HealthSettle is how long a unit without a health endpoint must stay
+// active, without being restarted, to count as healthy.
+var HealthSettle = 5 * time.Second
+
+// journalLines is how much of the unit's journal an unhealthy error carries.
+const journalLines = 20
+
+// Probe returns a command that succeeds when svc is healthy: its health
+// endpoint answers with a success status or, without one, its unit stays
+// active for HealthSettle without systemd restarting it, so that a binary
+// crashing on start under Restart=always is not taken for healthy, and
+// listens on its port if it has one. It returns "" for components without
+// a unit.
+func Probe(svc Service) string {
+	switch {
+	case svc.Health != "" && svc.Port != 0:
+		return "curl -fsS -o /dev/null --max-time 5 http://127.0.0.1:" + strconv.Itoa(svc.Port) + svc.Health
+	case svc.Unit != "":
+		probe := fmt.Sprintf(`n=$(systemctl show -p NRestarts --value %[1]s) && systemctl is-active --quiet %[1]s && `+
+			`sleep %[2]d && systemctl is-active --quiet %[1]s && [ "$(systemctl show -p NRestarts --value %[1]s)" = "$n" ]`,
+			svc.Unit, int(HealthSettle.Seconds()))
+		if svc.Port != 0 {
+			probe += " && " + Listening(svc.Port)
+		}
+		return probe
+	}
+	return ""
+}
+
+// WaitHealthy polls the probe of svc until it succeeds or timeout passes.
+// The error then carries the last lines of the unit's journal.
+func (s *Session) WaitHealthy(svc Service, timeout time.Duration) error {
+	probe := Probe(svc)
+	if probe == "" {
+		return nil
+	}
+
+	log := s.log
+	client, err := s.connect()
+	if err != nil {
+		return err
+	}
+
+	log.Printf("⏳ Waiting up to %s for %s to become healthy", timeout, svc.Unit)
+	deadline := time.Now().Add(timeout)
+	for {
+		if _, err := client.Exec(probe); err == nil {
+			log.Printf("💚 %s is healthy", svc.Unit)
+			return nil
+		}
+		if time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(HealthInterval)
+	}
+
+	log.Printf("💔 %s did not become healthy", svc.Unit)
+	out, _ := client.Exec(fmt.Sprintf("journalctl -u %s -n %d --no-pager", svc.Unit, journalLines))
+	return fmt.Errorf("%s not healthy after %s (%s); last journal lines:\n%s",
+		svc.Unit, timeout, probe, Redact(strings.TrimSpace(string(out)), s.server.Secrets()...))
+}
@@ -0,0 +1,138 @@
+// internal/servercomponents/executor/hostkeys.go
+package executor
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/elsgaard/firstmate/internal"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// knownHostsMu serialises trust-on-first-use writes to known_hosts files.
+var knownHostsMu sync.Mutex
+
+// knownHostsPath returns the known_hosts file used for server.
+func knownHostsPath(server internal.Server) (string, error) {
+	if server.KnownHosts != "" {
+		return server.KnownHosts, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("locate known_hosts: %w", err)
+	}
+	return filepath.Join(home, ".ssh", "known_hosts"), nil
+}
+
+// hostKeyCheck returns a callback verifying the host key against known_hosts,
+// and the key algorithms already on record for addr so that the server
+// presents a key of a type we can compare.
+func hostKeyCheck(server internal.Server, addr string) (ssh.HostKeyCallback, []string, error) {
+	path, err := knownHostsPath(server)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if server.TrustOnFirstUse {
+		if err := ensureFile(path); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	knownHostsMu.Lock()
+	check, err := knownhosts.New(path)
+	knownHostsMu.Unlock()
+	if err != nil {
+		return nil, nil, fmt.Errorf("load known_hosts %s: %w", path, err)
+	}
+
+	callback := func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := check(hostname, remote, key)
+
+		var keyErr *knownhosts.KeyError
+		if !errors.As(err, &keyErr) {
+			return err
+		}
+
+		if len(keyErr.Want) > 0 {
+			return fmt.Errorf("host key for %s has CHANGED (now %s %s); refusing to connect, possible impersonation",
+				hostname, key.Type(), ssh.FingerprintSHA256(key))
+		}
+
+		if !server.TrustOnFirstUse {
+			return fmt.Errorf("host %s is not in %s (%s %s); add it or use --trust-on-first-use",
+				hostname, path, key.Type(), ssh.FingerprintSHA256(key))
+		}
+
+		log.Printf("🔑 Trusting new host key for %s: %s %s", hostname, key.Type(), ssh.FingerprintSHA256(key))
+		return appendKnownHost(path, hostname, remote, key)
+	}
+
+	return callback, knownAlgorithms(check, addr), nil
+}
+
+// knownAlgorithms lists the key types recorded for addr. It probes the checker
+// with a throwaway key and reads the expected keys from the mismatch error.
+func knownAlgorithms(check ssh.HostKeyCallback, addr string) []string {
+	probe, err := ssh.NewPublicKey(ed25519.PublicKey(make([]byte, ed25519.PublicKeySize)))
+	if err != nil {
+		return nil
+	}
+
+	err = check(addr, &net.TCPAddr{IP: net.IPv4zero}, probe)
+
+	var keyErr *knownhosts.KeyError
+	if !errors.As(err, &keyErr) {
+		return nil
+	}
+
+	var algos []string
+	for _, want := range keyErr.Want {
+		switch t := want.Key.Type(); t {
+		case ssh.KeyAlgoRSA:
+			algos = append(algos, ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA)
+		default:
+			algos = append(algos, t)
+		}
+	}
+	return algos
+}
+
+func appendKnownHost(path, hostname string, remote net.Addr, key ssh.PublicKey) error {
+	knownHostsMu.Lock()
+	defer knownHostsMu.Unlock()
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("record host key: %w", err)
+	}
+	defer f.Close()
+
+	addrs := []string{knownhosts.Normalize(hostname)}
+	if remote != nil {
+		if ip := knownhosts.Normalize(remote.String()); ip != addrs[0] {
+			addrs = append(addrs, ip)
+		}
+	}
+
+	_, err = fmt.Fprintln(f, knownhosts.Line(addrs, key))
+	return err
+}
+
+func ensureFile(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,108 @@
+// internal/servercomponents/executor/params.go
+package executor
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// Render executes the text/template tmpl with data. Templates are fixed in
+// code, so a failure is a programming error and panics.
+func Render(tmpl string, data any) string {
+	t := template.Must(template.New("").Option("missingkey=error").Parse(tmpl))
+
+	var b strings.Builder
+	if err := t.Execute(&b, data); err != nil {
+		panic(err)
+	}
+	return b.String()
+}
+
+// SetParams overrides fields of the struct params points to. Keys of values
+// match the fields' `param` tags; string, int and bool fields are supported.
+func SetParams(params any, values map[string]string) error {
+	v := reflect.ValueOf(params).Elem()
+	fields := paramFields(v.Type())
+
+	for key, raw := range values {
+		i, ok := fields[key]
+		if !ok {
+			return fmt.Errorf("unknown parameter %q (known: %s)", key, strings.Join(ParamNames(v.Interface()), ", "))
+		}
+
+		f := v.Field(i)
+		switch f.Kind() {
+		case reflect.String:
+			f.SetString(raw)
+		case reflect.Int:
+			n, err := strconv.Atoi(raw)
+			if err != nil {
+				return fmt.Errorf("parameter %s: %q is not a number", key, raw)
+			}
+			f.SetInt(int64(n))
+		case reflect.Bool:
+			b, err := strconv.ParseBool(raw)
+			if err != nil {
+				return fmt.Errorf("parameter %s: %q is not a boolean", key, raw)
+			}
+			f.SetBool(b)
+		default:
+			return fmt.Errorf("parameter %s: unsupported type %s", key, f.Type())
+		}
+	}
+	return nil
+}
+
+// Override returns defaults with values applied. Invalid values leave the
+// defaults in place; callers validate them up front with CheckParams.
+func Override[T any](defaults T, values map[string]string) T {
+	p := defaults
+	if err := SetParams(&p, values); err != nil {
+		return defaults
+	}
+	return p
+}
+
+// CheckParams reports whether values are valid overrides for defaults, a
+// parameter struct. A nil defaults accepts no parameters.
+func CheckParams(defaults any, values map[string]string) error {
+	if len(values) == 0 {
+		return nil
+	}
+	if defaults == nil {
+		return fmt.Errorf("takes no parameters")
+	}
+
+	p := reflect.New(reflect.TypeOf(defaults))
+	p.Elem().Set(reflect.ValueOf(defaults))
+	return SetParams(p.Interface(), values)
+}
+
+// ParamNames lists the parameter keys of a parameter struct.
+func ParamNames(params any) []string {
+	if params == nil {
+		return nil
+	}
+
+	t := reflect.TypeOf(params)
+	var names []string
+	for i := range t.NumField() {
+		if name := t.Field(i).Tag.Get("param"); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func paramFields(t reflect.Type) map[string]int {
+	fields := map[string]int{}
+	for i := range t.NumField() {
+		if name := t.Field(i).Tag.Get("param"); name != "" {
+			fields[name] = i
+		}
+	}
+	return fields
+}
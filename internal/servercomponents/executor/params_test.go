@@ -0,0 +1,109 @@
+package executor
+
+import (
+	"strings"
+	"testing"
+)
+
+type testParams struct {
+	Version string  `param:"version"`
+	Port    int     `param:"port"`
+	TLS     bool    `param:"tls"`
+	Ratio   float64 `param:"ratio"`
+	Hidden  string
+}
+
+func TestSetParams(t *testing.T) {
+	defaults := testParams{Version: "1.0.0", Port: 9100, Hidden: "kept"}
+
+	tests := []struct {
+		name    string
+		values  map[string]string
+		want    testParams
+		wantErr string
+	}{
+		{name: "no values", want: defaults},
+		{
+			name:   "string, int and bool",
+			values: map[string]string{"version": "2.1.0", "port": "9182", "tls": "true"},
+			want:   testParams{Version: "2.1.0", Port: 9182, TLS: true, Hidden: "kept"},
+		},
+		{
+			name:   "empty string",
+			values: map[string]string{"version": ""},
+			want:   testParams{Port: 9100, Hidden: "kept"},
+		},
+		{name: "unknown key", values: map[string]string{"Version": "2"}, wantErr: `unknown parameter "Version" (known: version, port, tls, ratio)`},
+		{name: "untagged field", values: map[string]string{"Hidden": "x"}, wantErr: "unknown parameter"},
+		{name: "bad int", values: map[string]string{"port": "90a"}, wantErr: `parameter port: "90a" is not a number`},
+		{name: "bad bool", values: map[string]string{"tls": "yes"}, wantErr: `parameter tls: "yes" is not a boolean`},
+		{name: "unsupported type", values: map[string]string{"ratio": "0.5"}, wantErr: "parameter ratio: unsupported type float64"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := defaults
+			err := SetParams(&p, tt.values)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("SetParams error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p != tt.want {
+				t.Errorf("SetParams = %+v, want %+v", p, tt.want)
+			}
+		})
+	}
+}
+
+func TestOverride(t *testing.T) {
+	defaults := testParams{Version: "1.0.0", Port: 9100}
+
+	tests := []struct {
+		name   string
+		values map[string]string
+		want   testParams
+	}{
+		{name: "nil values", want: defaults},
+		{name: "applied", values: map[string]string{"port": "9182"}, want: testParams{Version: "1.0.0", Port: 9182}},
+		{name: "invalid keeps every default", values: map[string]string{"version": "2.0.0", "port": "x"}, want: defaults},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Override(defaults, tt.values); got != tt.want {
+				t.Errorf("Override = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+	if defaults.Port != 9100 {
+		t.Errorf("Override changed the defaults: %+v", defaults)
+	}
+}
+
+func TestCheckParams(t *testing.T) {
+	tests := []struct {
+		name     string
+		defaults any
+		values   map[string]string
+		wantErr  string
+	}{
+		{name: "no values", defaults: nil},
+		{name: "valid", defaults: testParams{}, values: map[string]string{"port": "1"}},
+		{name: "invalid", defaults: testParams{}, values: map[string]string{"port": "x"}, wantErr: "is not a number"},
+		{name: "component without parameters", defaults: nil, values: map[string]string{"port": "1"}, wantErr: "takes no parameters"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckParams(tt.defaults, tt.values)
+			if tt.wantErr == "" && err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("CheckParams error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+// internal/servercomponents/executor/redact.go
+package executor
+
+import (
+	"io"
+	"slices"
+	"strings"
+)
+
+// Mask is what secrets are replaced with in any output.
+const Mask = "********"
+
+// Redact replaces every occurrence of the given secrets in s with Mask.
+func Redact(s string, secrets ...string) string {
+	// Longest first, so a secret containing another is masked as a whole.
+	secrets = slices.Clone(secrets)
+	slices.SortFunc(secrets, func(a, b string) int { return len(b) - len(a) })
+
+	for _, secret := range secrets {
+		if secret != "" {
+			s = strings.ReplaceAll(s, secret, Mask)
+		}
+	}
+	return s
+}
+
+// redactWriter masks secrets in everything written through it. The log
+// package issues one Write per line, so a secret is never split across calls.
+type redactWriter struct {
+	w       io.Writer
+	secrets []string
+}
+
+func (rw redactWriter) Write(p []byte) (int, error) {
+	if len(rw.secrets) == 0 {
+		return rw.w.Write(p)
+	}
+	if _, err := io.WriteString(rw.w, Redact(string(p), rw.secrets...)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
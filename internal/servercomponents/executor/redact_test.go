@@ -0,0 +1,35 @@
+package executor
+
+import "testing"
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		secrets []string
+		want    string
+	}{
+		{"no secrets", "pass=hunter2", nil, "pass=hunter2"},
+		{"empty secret ignored", "pass=hunter2", []string{""}, "pass=hunter2"},
+		{"every occurrence", "hunter2 and hunter2", []string{"hunter2"}, Mask + " and " + Mask},
+		{"several secrets", "user=bob pass=hunter2", []string{"bob", "hunter2"}, "user=" + Mask + " pass=" + Mask},
+		{"containing secret masked whole", "token=abc123", []string{"abc", "abc123"}, "token=" + Mask},
+		{"containing secret listed last", "token=abc123", []string{"abc123", "abc"}, "token=" + Mask},
+		{"absent secret", "nothing here", []string{"hunter2"}, "nothing here"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Redact(tt.in, tt.secrets...); got != tt.want {
+				t.Errorf("Redact(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactDoesNotReorderSecrets(t *testing.T) {
+	secrets := []string{"a", "abc"}
+	Redact("abc", secrets...)
+	if secrets[0] != "a" || secrets[1] != "abc" {
+		t.Errorf("secrets reordered to %v", secrets)
+	}
+}
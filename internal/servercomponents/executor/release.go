@@ -0,0 +1,153 @@
+// internal/servercomponents/executor/release.go
+package executor
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Release is a versioned tarball published on a GitHub releases page, named
+// like prometheus-3.5.0.linux-amd64.tar.gz, for one architecture.
+type Release struct {
+	Repo     string   // e.g. prometheus/prometheus
+	Name     string   // archive prefix, e.g. prometheus
+	Version  string   // with or without the leading v
+	Arch     string   // e.g. arm64, defaults to amd64
+	Binaries []string // moved to /usr/local/bin, the first reports the version
+	SHA256   string   // expected archive checksum; empty uses upstream's sha256sums.txt
+	Push     bool     // download on the operator machine and upload the archive
+}
+
+// version returns the version without the leading v.
+func (r Release) version() string {
+	return strings.TrimPrefix(r.Version, "v")
+}
+
+// dir returns the directory the archive unpacks into.
+func (r Release) dir() string {
+	arch := r.Arch
+	if arch == "" {
+		arch = "amd64"
+	}
+	return fmt.Sprintf("%s-%s.linux-%s", r.Name, r.version(), arch)
+}
+
+// Archive returns the file name of the tarball.
+func (r Release) Archive() string {
+	return r.dir() + ".tar.gz"
+}
+
+// URL returns the download location of the tarball.
+func (r Release) URL() string {
+	return fmt.Sprintf("https://github.com/%s/releases/download/v%s/%s", r.Repo, r.version(), r.Archive())
+}
+
+// sumsURL returns the location of the checksums published with the release.
+func (r Release) sumsURL() string {
+	return fmt.Sprintf("https://github.com/%s/releases/download/v%s/sha256sums.txt", r.Repo, r.version())
+}
+
+// verify returns a command that checks the downloaded archive against the
+// pinned checksum, or the published one, and deletes it on a mismatch.
+func (r Release) verify() string {
+	sums := r.Archive() + ".sha256"
+
+	expected := fmt.Sprintf("echo '%s  %s' > %s", r.SHA256, r.Archive(), sums)
+	if r.SHA256 == "" {
+		expected = fmt.Sprintf("wget -q -O - %s | awk -v f=%s '$2 == f' > %s", r.sumsURL(), r.Archive(), sums)
+	}
+
+	return fmt.Sprintf("%s && sha256sum --check %s || { rm -f %s %s; false; }", expected, sums, r.Archive(), sums)
+}
+
+// artifact returns the archive as fetched into the operator's cache. The
+// checksum is verified before the archive is cached.
+func (r Release) artifact() Artifact {
+	return Artifact{
+		Name: r.Archive(),
+		Fetch: func(dst string) error {
+			if err := download(r.URL(), dst); err != nil {
+				return err
+			}
+
+			want := r.SHA256
+			if want == "" {
+				var err error
+				if want, err = r.publishedSum(); err != nil {
+					return err
+				}
+			}
+
+			got, err := fileSHA256(dst)
+			if err != nil {
+				return err
+			}
+			if got != want {
+				return fmt.Errorf("checksum mismatch for %s: got %s, want %s", r.Archive(), got, want)
+			}
+			return nil
+		},
+	}
+}
+
+// publishedSum looks up the archive's checksum in the release's sha256sums.txt.
+func (r Release) publishedSum() (string, error) {
+	resp, err := http.Get(r.sumsURL())
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("GET %s: %s", r.sumsURL(), resp.Status)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) == 2 && fields[1] == r.Archive() {
+			return fields[0], nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("%s not listed in %s", r.Archive(), r.sumsURL())
+}
+
+// Installed returns a check that succeeds when the release's first binary
+// already reports its version.
+func (r Release) Installed() string {
+	return BinaryVersion("/usr/local/bin/"+r.Binaries[0], r.version())
+}
+
+// InstallRelease returns the steps that download r, verify its checksum and
+// move its binaries into /usr/local/bin, replacing any other version, then
+// remove the download. A checksum mismatch aborts before anything is
+// extracted. They are skipped when the version is already installed; a
+// version being replaced is kept for RollbackRelease.
+//
+// With Push set the archive is fetched into the operator's cache, verified
+// there and uploaded instead, so the host needs no internet access.
+func InstallRelease(r Release) []Step {
+	installed := r.Installed()
+	move := "cd " + r.dir() + " && mv " + strings.Join(r.Binaries, " ") + " /usr/local/bin/ && cd .. && rm -rf " + r.dir()
+
+	if r.Push {
+		a := r.artifact()
+		return []Step{
+			r.saveBinaries(),
+			Push(a, "tar -xvzf "+a.HostPath()).Unless(installed),
+			Cmd(move + " " + a.HostPath()).Unless(installed),
+		}
+	}
+
+	return []Step{
+		r.saveBinaries(),
+		Cmd("wget -q -O " + r.Archive() + " " + r.URL()).WithRetry(3).Unless(installed),
+		Custom("verify "+r.Archive(), r.verify()).Unless(installed),
+		Cmd("tar -xvzf " + r.Archive()).Unless(installed),
+		Cmd(move + " " + r.Archive() + " " + r.Archive() + ".sha256").Unless(installed),
+	}
+}
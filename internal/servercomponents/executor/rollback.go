@@ -0,0 +1,81 @@
+// internal/servercomponents/executor/rollback.go
+package executor
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// StateDir holds what firstmate records on the host between runs, such as
+// the release that was running before the last update.
+const StateDir = "/var/lib/firstmate"
+
+// previous returns where the pre-update state of name is kept.
+func previous(name string) string {
+	return StateDir + "/" + name + ".previous"
+}
+
+// recordRevision returns a step saving the commit checked out for s before
+// it is updated on the host. It runs after the fetch and is skipped when the
+// checkout already sits on the commit to deploy, so that re-running an update
+// keeps the release it replaced.
+func (s Source) recordRevision() Step {
+	return Custom("record revision of "+path.Base(s.Repo),
+		fmt.Sprintf("mkdir -p %s && git -C %s rev-parse HEAD > %s", StateDir, s.dir(), previous(path.Base(s.Repo)))).
+		Unless(s.upToDate())
+}
+
+// RemoveSource returns a step deleting the /opt directory of s together with
+// the previous release kept for RollbackSource.
+func RemoveSource(s Source) Step {
+	return Cmd(fmt.Sprintf("rm -rf %[1]s %[1]s.prev %[2]s", s.dir(), previous(path.Base(s.Repo))))
+}
+
+// RollbackSource returns a step restoring the release of s that ran before
+// the last update: a shipped build is swapped back in, a checkout is reset
+// to the recorded commit and rebuilt.
+func RollbackSource(s Source) []Step {
+	dir, prev := s.dir(), previous(path.Base(s.Repo))
+	return []Step{
+		Custom("restore previous "+path.Base(s.Repo), fmt.Sprintf(
+			`if [ -d %[1]s/.git ]; then test -s %[2]s && git -C %[1]s reset --hard "$(cat %[2]s)" && cd %[1]s && make build; `+
+				`elif [ -d %[1]s.prev ]; then rm -rf %[1]s.rollback && mv %[1]s %[1]s.rollback && mv %[1]s.prev %[1]s && rm -rf %[1]s.rollback; `+
+				`else echo "no previous release of %[3]s recorded" >&2; false; fi`,
+			dir, prev, path.Base(s.Repo))),
+	}
+}
+
+// saveBinaries returns a step copying the installed binaries of r aside
+// before another version replaces them. It is skipped on a fresh install.
+func (r Release) saveBinaries() Step {
+	bin := "/usr/local/bin/" + r.Binaries[0]
+	var paths []string
+	for _, b := range r.Binaries {
+		paths = append(paths, "/usr/local/bin/"+b)
+	}
+	prev := previous(r.Name)
+	return Custom("save "+r.Name+" binaries", fmt.Sprintf("rm -rf %[1]s && mkdir -p %[1]s && cp -p %[2]s %[1]s/", prev, strings.Join(paths, " "))).
+		Unless(r.Installed() + " || ! " + PathExists(bin))
+}
+
+// RemoveRelease returns a step deleting the binaries of r together with
+// those saved for RollbackRelease.
+func RemoveRelease(r Release) Step {
+	var paths []string
+	for _, b := range r.Binaries {
+		paths = append(paths, "/usr/local/bin/"+b)
+	}
+	return Cmd("rm -rf " + strings.Join(paths, " ") + " " + previous(r.Name))
+}
+
+// RollbackRelease returns a step putting back the binaries saved by the
+// last update of r.
+func RollbackRelease(r Release) []Step {
+	prev := previous(r.Name)
+	return []Step{
+		Custom("restore previous "+r.Name, fmt.Sprintf(
+			`if [ -d %[1]s ]; then cp -p %[1]s/* /usr/local/bin/; else echo "no previous release of %[2]s recorded" >&2; false; fi`,
+			prev, r.Name)),
+	}
+}
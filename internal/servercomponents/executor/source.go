@@ -0,0 +1,195 @@
+// internal/servercomponents/executor/source.go
+package executor
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/elsgaard/firstmate/internal"
+)
+
+// RevisionFile records, inside a locally built checkout directory, the
+// revision the shipped binary was built from.
+const RevisionFile = ".revision"
+
+// Source is a component built with make build from a GitHub repository
+// checked out under /opt.
+type Source struct {
+	Repo   string   // "owner/name"
+	Binary string   // produced by make build, relative to the checkout
+	Assets []string // other files the service needs at runtime, relative to the checkout
+	Ref    string   // branch, tag or commit to deploy, defaults to main
+}
+
+func (s Source) dir() string {
+	return RepoDir(s.Repo)
+}
+
+func (s Source) ref() string {
+	if s.Ref == "" {
+		return "main"
+	}
+	return s.Ref
+}
+
+// target returns a shell expression naming the commit to deploy in the
+// host's checkout: the remote branch when ref is one, else ref itself.
+func (s Source) target() string {
+	if s.ref() == "main" {
+		return "origin/main"
+	}
+	ref := quote(s.ref() + "^{commit}")
+	return fmt.Sprintf(`"$(git -C %[1]s rev-parse --verify --quiet origin/%[2]s || git -C %[1]s rev-parse --verify %[2]s)"`, s.dir(), ref)
+}
+
+// InstallSource returns the steps that put s, built at its ref, under
+// /opt, skipped when the binary is already there. By default the repository
+// is cloned and built on the host; with server.BuildLocal it is built on the
+// operator machine and only the binary and assets are uploaded.
+func InstallSource(server internal.Server, s Source) []Step {
+	built := PathExists(s.dir() + "/" + s.Binary)
+
+	if server.BuildLocal {
+		return []Step{s.ship(server).Unless(built)}
+	}
+
+	steps := []Step{GitClone(server, s.Repo)}
+	if s.ref() != "main" {
+		steps = append(steps, Cmd("git -C "+s.dir()+" reset --hard "+s.target()).Unless(built))
+	}
+	return append(steps, Cmd("cd "+s.dir()+" && make build").Unless(built))
+}
+
+// UpdateSource returns the steps that bring s to the latest commit of its
+// ref, passing fetchArgs to git fetch when building main on the host. The
+// release being replaced is kept for RollbackSource.
+func UpdateSource(server internal.Server, s Source, fetchArgs ...string) []Step {
+	if server.BuildLocal {
+		return []Step{s.ship(server)}
+	}
+	if s.ref() != "main" {
+		fetchArgs = []string{"--all", "--tags"}
+	}
+	return []Step{
+		GitFetch(server, s.Repo, fetchArgs...).WithRetry(3),
+		s.recordRevision(),
+		Cmd("git -C " + s.dir() + " reset --hard " + s.target()),
+		Cmd("cd " + s.dir() + " && make build"),
+	}
+}
+
+// upToDate succeeds when the host's checkout of s already sits on the commit
+// to deploy.
+func (s Source) upToDate() string {
+	return fmt.Sprintf(`[ "$(git -C %[1]s rev-parse HEAD)" = "$(git -C %[1]s rev-parse --verify %[2]s)" ]`, s.dir(), s.target())
+}
+
+// SourceVersion returns a command describing the deployed revision of s,
+// whether built on the host or shipped.
+func SourceVersion(s Source) string {
+	return "cat " + s.dir() + "/" + RevisionFile + " 2>/dev/null || " + GitVersion(s.Repo)
+}
+
+// ship returns a step that uploads the locally built archive and swaps it
+// in for the checkout directory, leaving no source tree behind. A shipped
+// build it replaces is kept as <dir>.prev unless it has the same revision;
+// a git checkout it replaces is removed.
+func (s Source) ship(server internal.Server) Step {
+	a := s.artifact(server)
+	return Push(a, fmt.Sprintf("rm -rf %[1]s.new && mkdir -p %[1]s.new && tar -xzf %[2]s -C %[1]s.new && "+
+		"if [ -d %[1]s/.git ]; then rm -rf %[1]s %[1]s.prev; "+
+		"elif [ -d %[1]s ] && ! cmp -s %[1]s.new/%[3]s %[1]s/%[3]s; then rm -rf %[1]s.prev && mv %[1]s %[1]s.prev; "+
+		"else rm -rf %[1]s; fi && mv %[1]s.new %[1]s && rm -f %[2]s",
+		s.dir(), a.HostPath(), RevisionFile))
+}
+
+// artifact returns the archive of the binary and assets, built once per
+// run from the ref in the operator's mirror for the host's architecture.
+func (s Source) artifact(server internal.Server) Artifact {
+	arch := server.Arch
+	if arch == "" {
+		arch = "amd64"
+	}
+	name := path.Base(s.Repo)
+	base := fmt.Sprintf("%s-%s.linux-%s", name, strings.ReplaceAll(s.ref(), "/", "-"), arch)
+
+	return Artifact{
+		Name:    base + ".tar.gz",
+		Refresh: true,
+		Fetch: func(dst string) error {
+			mirror, err := syncMirror(server, s.Repo)
+			if err != nil {
+				return err
+			}
+
+			// One directory per artifact, so that builds of other refs or
+			// architectures running at the same time do not collide.
+			work := filepath.Join(CacheDir(), "build", base)
+			if err := os.RemoveAll(work); err != nil {
+				return err
+			}
+			if err := localGit(server, "clone", "--quiet", "--no-checkout", mirror, work); err != nil {
+				return err
+			}
+			commit, err := resolve(work, s.ref())
+			if err != nil {
+				return err
+			}
+			if err := localGit(server, "-C", work, "checkout", "--quiet", "--detach", commit); err != nil {
+				return err
+			}
+
+			rev, err := exec.Command("git", "-C", work, "describe", "--tags", "--always", "--long").Output()
+			if err != nil {
+				return fmt.Errorf("describe %s: %w", name, err)
+			}
+			if err := os.WriteFile(filepath.Join(work, RevisionFile), rev, 0o644); err != nil {
+				return err
+			}
+
+			build := exec.Command("make", "build")
+			build.Dir = work
+			build.Env = append(os.Environ(), goEnv(arch)...)
+			if out, err := build.CombinedOutput(); err != nil {
+				return fmt.Errorf("make build for %s/%s: %v: %s", name, arch, err, strings.TrimSpace(string(out)))
+			}
+
+			files := append([]string{s.Binary, RevisionFile}, s.Assets...)
+			if out, err := exec.Command("tar", append([]string{"-czf", dst, "-C", work}, files...)...).CombinedOutput(); err != nil {
+				return fmt.Errorf("pack %s: %v: %s", name, err, strings.TrimSpace(string(out)))
+			}
+			return nil
+		},
+	}
+}
+
+// resolve returns the commit ref names in the clone at dir, preferring a
+// remote branch over a tag or commit of the same name.
+func resolve(dir, ref string) (string, error) {
+	for _, name := range []string{"origin/" + ref, ref} {
+		out, err := exec.Command("git", "-C", dir, "rev-parse", "--verify", "--quiet", name+"^{commit}").Output()
+		if err == nil {
+			return strings.TrimSpace(string(out)), nil
+		}
+	}
+	return "", fmt.Errorf("unknown ref %q", ref)
+}
+
+// quote returns s as a single-quoted shell word.
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// goEnv returns the Go environment cross-compiling for arch on Linux.
+func goEnv(arch string) []string {
+	switch arch {
+	case "armv7":
+		return []string{"GOOS=linux", "GOARCH=arm", "GOARM=7"}
+	default:
+		return []string{"GOOS=linux", "GOARCH=" + arch}
+	}
+}
@@ -0,0 +1,118 @@
+// internal/servercomponents/executor/status.go
+package executor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/elsgaard/firstmate/internal"
+	"github.com/sfreiberg/simplessh"
+)
+
+// File states reported by Inspect.
+const (
+	FileInSync  = "in sync"
+	FileDiffers = "differs"
+	FileMissing = "missing"
+)
+
+// Report is the live state of a component's service on a host.
+type Report struct {
+	Loaded    bool   // the unit file exists
+	Enabled   string // systemd UnitFileState, e.g. "enabled"
+	Active    string // systemd ActiveState, e.g. "active" or "failed"
+	Version   string // first line printed by Service.Version
+	Files     map[string]string
+	Listening bool // something listens on Service.Port
+}
+
+// Inspect connects to server and reports the state of svc without changing
+// anything on the host.
+func Inspect(server internal.Server, svc Service) (Report, error) {
+	rep := Report{Files: map[string]string{}}
+
+	client, err := Connect(server)
+	if err != nil {
+		return rep, fmt.Errorf("SSH connection failed: %w", err)
+	}
+	defer client.Close()
+
+	if svc.Unit != "" {
+		out, err := client.Exec("systemctl show " + svc.Unit + " -p LoadState -p UnitFileState -p ActiveState")
+		if err != nil {
+			return rep, fmt.Errorf("query %s: %w", svc.Unit, err)
+		}
+		props := parseProperties(string(out))
+		rep.Loaded = props["LoadState"] == "loaded"
+		rep.Enabled = props["UnitFileState"]
+		rep.Active = props["ActiveState"]
+	}
+
+	if svc.Version != "" {
+		if out, err := client.Exec(svc.Version); err == nil {
+			rep.Version, _, _ = strings.Cut(strings.TrimSpace(string(out)), "\n")
+		}
+	}
+
+	for _, f := range svc.Files {
+		rep.Files[f.Path] = fileState(client, f)
+	}
+
+	if svc.Port != 0 {
+		_, err := client.Exec(Listening(svc.Port))
+		rep.Listening = err == nil
+	}
+
+	return rep, nil
+}
+
+// Revision returns the first line printed by cmd, a Service.Version
+// command.
+func (s *Session) Revision(cmd string) (string, error) {
+	client, err := s.connect()
+	if err != nil {
+		return "", err
+	}
+
+	out, err := client.Exec(cmd)
+	if err != nil {
+		return "", err
+	}
+	line, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	return line, nil
+}
+
+// ReadFile returns the content of path on the host and whether it exists.
+func ReadFile(client *simplessh.Client, path string) (string, bool, error) {
+	out, err := client.Exec("cat " + path)
+	if err != nil {
+		if _, statErr := client.Exec("test -e " + path); statErr != nil {
+			return "", false, nil
+		}
+		return "", true, fmt.Errorf("read %s: %w", path, err)
+	}
+	return string(out), true, nil
+}
+
+func fileState(client *simplessh.Client, f File) string {
+	content, exists, err := ReadFile(client, f.Path)
+	switch {
+	case err != nil:
+		return err.Error()
+	case !exists:
+		return FileMissing
+	case content != f.Data():
+		return FileDiffers
+	}
+	return FileInSync
+}
+
+func parseProperties(out string) map[string]string {
+	props := map[string]string{}
+	for _, line := range strings.Split(out, "\n") {
+		if k, v, ok := strings.Cut(strings.TrimSpace(line), "="); ok {
+			props[k] = v
+		}
+	}
+	return props
+}
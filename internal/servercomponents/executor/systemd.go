@@ -0,0 +1,28 @@
+// internal/servercomponents/executor/systemd.go
+package executor
+
+// DaemonReload returns a step reloading systemd, skipped when unit is loaded
+// and its file has not changed since.
+func DaemonReload(unit string) Step {
+	return Cmd("systemctl daemon-reload").Unless(
+		`[ "$(systemctl show -p LoadState --value ` + unit + `)" = loaded ] && ` +
+			`[ "$(systemctl show -p NeedDaemonReload --value ` + unit + `)" = no ]`)
+}
+
+// EnableNow returns a step enabling and (re)starting unit. It is skipped
+// when the unit is already enabled and active, unless an earlier step of the
+// run changed its files or binaries.
+func EnableNow(unit string) Step {
+	return Cmd("systemctl enable " + unit + " && systemctl restart " + unit).Unless(UnitRunning(unit)).OnChange()
+}
+
+// RemoveUnit returns the steps that stop, disable and delete a systemd unit
+// installed under /etc/systemd/system.
+func RemoveUnit(unit string) []Step {
+	return []Step{
+		Cmd("systemctl disable --now " + unit).IgnoreErrors(),
+		Cmd("rm -f /etc/systemd/system/" + unit),
+		Cmd("systemctl daemon-reload"),
+		Cmd("systemctl reset-failed " + unit).IgnoreErrors(),
+	}
+}
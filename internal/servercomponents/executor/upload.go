@@ -0,0 +1,168 @@
+// internal/servercomponents/executor/upload.go
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// upload writes f over SFTP to a temporary file beside its target, sets
+// owner and mode, and atomically renames it into place. SFTP does not go
+// through sudo, so this needs an SSH login as root.
+func (r runner) upload(f File) error {
+	uid, gid, err := r.lookupOwner(f.owner())
+	if err != nil {
+		return err
+	}
+
+	client, err := r.client.SFTPClient()
+	if err != nil {
+		return fmt.Errorf("sftp: %w", err)
+	}
+	defer client.Close()
+
+	tmp := f.Path + ".firstmate-tmp"
+	w, err := client.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if errors.Is(err, os.ErrPermission) {
+		return fmt.Errorf("create %s: %w (firstmate must log in as root)", tmp, err)
+	}
+	if err != nil {
+		return fmt.Errorf("create %s: %w", tmp, err)
+	}
+
+	_, err = io.WriteString(w, f.Data())
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = client.Chmod(tmp, f.mode())
+	}
+	if err == nil {
+		err = client.Chown(tmp, uid, gid)
+	}
+	if err == nil {
+		err = client.PosixRename(tmp, f.Path)
+	}
+	if err != nil {
+		client.Remove(tmp)
+		return fmt.Errorf("upload %s: %w", f.Path, err)
+	}
+	return nil
+}
+
+// push uploads the cached artifact a to PushDir and checks that the copy on
+// the host matches the cache.
+func (r runner) push(a Artifact) error {
+	local, err := a.Local()
+	if err != nil {
+		return err
+	}
+	sum, err := fileSHA256(local)
+	if err != nil {
+		return err
+	}
+
+	src, err := os.Open(local)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	client, err := r.client.SFTPClient()
+	if err != nil {
+		return fmt.Errorf("sftp: %w", err)
+	}
+	defer client.Close()
+
+	if err := client.MkdirAll(PushDir); err != nil {
+		return fmt.Errorf("create %s: %w", PushDir, err)
+	}
+
+	dst := a.HostPath()
+	tmp := dst + ".firstmate-tmp"
+	w, err := client.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return fmt.Errorf("create %s: %w", tmp, err)
+	}
+
+	_, err = io.Copy(w, src)
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = client.PosixRename(tmp, dst)
+	}
+	if err != nil {
+		client.Remove(tmp)
+		return fmt.Errorf("push %s: %w", a.Name, err)
+	}
+
+	out, err := r.client.Exec("sha256sum " + dst)
+	if err != nil {
+		return fmt.Errorf("checksum %s: %w", dst, err)
+	}
+	if fields := strings.Fields(string(out)); len(fields) == 0 || fields[0] != sum {
+		return fmt.Errorf("push %s: copy on host does not match the cache", a.Name)
+	}
+	return nil
+}
+
+// download copies d.Remote from the host to d.Local over SFTP.
+func (r runner) download(d Download) error {
+	client, err := r.client.SFTPClient()
+	if err != nil {
+		return fmt.Errorf("sftp: %w", err)
+	}
+	defer client.Close()
+
+	src, err := client.Open(d.Remote)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", d.Remote, err)
+	}
+	defer src.Close()
+
+	if err := os.MkdirAll(filepath.Dir(d.Local), 0o700); err != nil {
+		return err
+	}
+	dst, err := os.OpenFile(d.Local, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(dst, src)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(d.Local)
+		return fmt.Errorf("download %s: %w", d.Remote, err)
+	}
+	return nil
+}
+
+// lookupOwner resolves a user name to its uid and primary gid on the host.
+func (r runner) lookupOwner(user string) (int, int, error) {
+	out, err := r.client.Exec("id -u " + user + " && id -g " + user)
+	if err != nil {
+		return 0, 0, fmt.Errorf("look up user %s: %w", user, err)
+	}
+
+	ids := strings.Fields(string(out))
+	if len(ids) != 2 {
+		return 0, 0, fmt.Errorf("look up user %s: unexpected output %q", user, out)
+	}
+	uid, err := strconv.Atoi(ids[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("look up user %s: %w", user, err)
+	}
+	gid, err := strconv.Atoi(ids[1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("look up user %s: %w", user, err)
+	}
+	return uid, gid, nil
+}
@@ -1,93 +1,97 @@
 package journexd
 
 import (
-	"fmt"
-	"log"
-	"strings"
-	"time"
-
 	"github.com/elsgaard/firstmate/internal"
-	"github.com/sfreiberg/simplessh"
+	"github.com/elsgaard/firstmate/internal/servercomponents/executor"
 )
 
 type Model struct{}
 
-func (m Model) Deploy(server internal.Server) error {
-	log.Printf("▶ Starting journexd deploy on %s", server.FQDN)
-	return m.executeRemoteCommands(server, m.getInstallCommands(server))
+// source is the repository the component is built from.
+var source = executor.Source{
+	Repo:   "TRUECOMMERCEDK/journexd",
+	Binary: "journexd",
 }
 
-func (m Model) Update(server internal.Server) error {
-	log.Printf("▶ Starting journexd update on %s", server.FQDN)
-	return m.executeRemoteCommands(server, m.getUpdateCommands())
+// Params select the git ref to deploy.
+type Params struct {
+	Ref string `param:"ref"`
 }
 
-// executeRemoteCommands centralizes connection, execution, and error handling.
-func (m Model) executeRemoteCommands(server internal.Server, cmds []string) error {
-	client, err := simplessh.ConnectWithPassword(server.FQDN, server.User, server.Pass)
-	if err != nil {
-		return fmt.Errorf("SSH connection failed: %w", err)
-	}
-	defer client.Close()
-
-	for _, cmd := range cmds {
-		serverCmd := m.checkCustomAction(cmd)
-
-		log.Printf("→ Executing: %s", serverCmd)
-		out, err := client.Exec(serverCmd)
-		if err != nil {
-			log.Printf("⚠️ Command failed: %v", err)
-			continue // `return err` to fail-fast, for now just continue
-		}
-		if len(out) > 0 {
-			log.Printf("→ Output: %s", strings.TrimSpace(string(out)))
-		}
-
-		time.Sleep(500 * time.Millisecond) // gentle pacing between commands
-	}
+// defaults apply to anything not overridden with --set or the inventory.
+var defaults = Params{
+	Ref: "main",
+}
 
-	log.Println("✅ journexd operation completed successfully")
-	return nil
+func (m Model) UpdateSteps(server internal.Server) []executor.Step {
+	return append(executor.UpdateSource(server, m.source(server), "--all", "--tags"),
+		executor.WriteFile(m.unitFile()),
+		executor.DaemonReload("journexd.service"),
+		executor.Cmd("systemctl restart journexd.service"),
+	)
 }
 
-func (m Model) getUpdateCommands() []string {
-	return []string{
-		"sudo systemctl stop journexd.service",
-		"git -C /opt/journexd fetch --all --tags",
-		"git -C /opt/journexd reset --hard origin/main",
-		"cd /opt/journexd && make build",
-		"CUSTOM: CreateUnitFile",
-		"systemctl daemon-reload",
-		"systemctl restart journexd.service",
-	}
+func (m Model) InstallSteps(server internal.Server) []executor.Step {
+	return append(executor.InstallSource(server, m.source(server)),
+		executor.Cmd("mkdir -p /etc/journexd").Unless(executor.DirExists("/etc/journexd")),
+		executor.Cmd("mkdir -p /etc/journex").Unless(executor.DirExists("/etc/journex")),
+		executor.Cmd("mkdir -p /var/lib/journexd").Unless(executor.DirExists("/var/lib/journexd")),
+		executor.Cmd("chmod 755 /var/lib/journexd").Unless(executor.HasMode("/var/lib/journexd", "755")),
+		executor.WriteFile(m.unitFile()),
+		executor.DaemonReload("journexd.service"),
+		executor.EnableNow("journexd.service"),
+	)
 }
 
-func (m Model) getInstallCommands(server internal.Server) []string {
-	return []string{
-		gitCloneCommand(server, "TRUECOMMERCEDK/journexd"),
-		"cd /opt/journexd && make build",
-		"mkdir -p /etc/journexd",
-		"mkdir -p /etc/journex",
-		"mkdir -p /var/lib/journexd",
-		"chmod 755 /var/lib/journexd",
-		"CUSTOM: CreateUnitFile",
-		"systemctl daemon-reload",
-		"systemctl enable --now journexd.service",
+func (m Model) RollbackSteps(server internal.Server) []executor.Step {
+	return append(executor.RollbackSource(source),
+		executor.WriteFile(m.unitFile()),
+		executor.DaemonReload("journexd.service"),
+		executor.Cmd("systemctl restart journexd.service"),
+	)
+}
+
+func (m Model) UninstallSteps(server internal.Server, purge bool) []executor.Step {
+	steps := append(executor.RemoveUnit("journexd.service"),
+		executor.RemoveSource(source),
+	)
+	if purge {
+		steps = append(steps,
+			executor.Cmd("rm -rf /etc/journexd /etc/journex /var/lib/journexd"),
+		)
 	}
+	return steps
 }
 
-// Custom action dispatcher.
-func (m Model) checkCustomAction(action string) string {
-	if strings.HasSuffix(action, "CreateUnitFile") {
-		return m.createUnitFile()
+func (m Model) Service(server internal.Server) executor.Service {
+	return executor.Service{
+		Unit:    "journexd.service",
+		Files:   []executor.File{m.unitFile()},
+		Version: executor.SourceVersion(source),
 	}
-	return action
 }
 
-// CreateUnitFile returns a properly escaped heredoc for remote tee.
-func (m Model) createUnitFile() string {
-	return `sudo bash -c 'cat > /etc/systemd/system/journexd.service <<EOF
-[Unit]
+func (m Model) Params() any {
+	return defaults
+}
+
+// NeedsArch is true when the binary is built locally for the host.
+func (m Model) NeedsArch(server internal.Server) bool {
+	return server.BuildLocal
+}
+
+// source returns the repository at the configured ref.
+func (m Model) source(server internal.Server) executor.Source {
+	src := source
+	src.Ref = executor.Override(defaults, server.Params).Ref
+	return src
+}
+
+// unitFile returns the systemd unit.
+func (m Model) unitFile() executor.File {
+	return executor.File{
+		Path: "/etc/systemd/system/journexd.service",
+		Content: `[Unit]
 Description=Journexd Service
 After=network.target
 StartLimitIntervalSec=0
@@ -102,14 +106,6 @@ ExecStart=/opt/journexd/journexd
 
 [Install]
 WantedBy=multi-user.target
-EOF'`
-}
-
-func gitCloneCommand(s internal.Server, repo string) string {
-	return fmt.Sprintf(
-		"cd /opt && git clone https://%s:%s@github.com/%s.git",
-		s.GHUser,
-		s.GHPass,
-		repo,
-	)
+`,
+	}
 }
@@ -1,92 +1,105 @@
 package morphocm
 
 import (
-	"fmt"
-	"log"
-	"strings"
-	"time"
-
 	"github.com/elsgaard/firstmate/internal"
-	"github.com/sfreiberg/simplessh"
+	"github.com/elsgaard/firstmate/internal/servercomponents/executor"
 )
 
 type Model struct{}
 
-func (m Model) Deploy(server internal.Server) error {
-	log.Printf("▶ Starting morpho cm deploy on %s", server.FQDN)
-	return m.executeRemoteCommands(server, m.getInstallCommands(server))
+// source is the repository the component is built from.
+var source = executor.Source{
+	Repo:   "TRUECOMMERCEDK/morphocm",
+	Binary: "morphocm",
 }
 
-func (m Model) Update(server internal.Server) error {
-	log.Printf("▶ Starting morpho cm update on %s", server.FQDN)
-	return m.executeRemoteCommands(server, m.getUpdateCommands())
+// Params select the git ref and the settings rendered into the unit file.
+type Params struct {
+	Ref     string `param:"ref"`
+	Port    int    `param:"port"`
+	Backups int    `param:"backups"` // database backups kept on the host
 }
 
-// executeRemoteCommands centralizes connection, execution, and error handling.
-func (m Model) executeRemoteCommands(server internal.Server, cmds []string) error {
-	client, err := simplessh.ConnectWithPassword(server.FQDN, server.User, server.Pass)
-	if err != nil {
-		return fmt.Errorf("SSH connection failed: %w", err)
-	}
-	defer client.Close()
-
-	for _, cmd := range cmds {
-		serverCmd := m.checkCustomAction(cmd)
-
-		log.Printf("→ Executing: %s", serverCmd)
-		out, err := client.Exec(serverCmd)
-		if err != nil {
-			log.Printf("⚠️ Command failed: %v", err)
-			continue // `return err` to fail-fast, for now just continue
-		}
-		if len(out) > 0 {
-			log.Printf("→ Output: %s", strings.TrimSpace(string(out)))
-		}
-
-		time.Sleep(500 * time.Millisecond) // gentle pacing between commands
-	}
+// defaults apply to anything not overridden with --set or the inventory.
+var defaults = Params{
+	Ref:     "main",
+	Backups: 7,
+	Port:    8089,
+}
 
-	log.Println("✅ morpho cm operation completed successfully")
-	return nil
+func (m Model) UpdateSteps(server internal.Server) []executor.Step {
+	steps := executor.BackupDB(server, "/var/lib/morphocm/morphocm.db", executor.Override(defaults, server.Params).Backups)
+	steps = append(steps, executor.UpdateSource(server, m.source(server), "--all", "--tags")...)
+	return append(steps,
+		executor.WriteFile(m.unitFile(server)),
+		executor.DaemonReload("morphocm.service"),
+		executor.Cmd("systemctl restart morphocm.service"),
+	)
 }
 
-func (m Model) getUpdateCommands() []string {
-	return []string{
-		"systemctl stop morphocm.service",
-		"git -C /opt/morphocm fetch --all --tags",
-		"git -C /opt/morphocm reset --hard origin/main",
-		"cd /opt/morphocm && make build",
-		"CUSTOM: CreateUnitFile",
-		"systemctl daemon-reload",
-		"systemctl restart morphocm.service",
-	}
+func (m Model) InstallSteps(server internal.Server) []executor.Step {
+	return append(executor.InstallSource(server, m.source(server)),
+		executor.Cmd("mkdir -p /etc/morphocm").Unless(executor.DirExists("/etc/morphocm")),
+		executor.Cmd("mkdir -p /var/lib/morphocm").Unless(executor.DirExists("/var/lib/morphocm")),
+		executor.Cmd("chmod 755 /var/lib/morphocm").Unless(executor.HasMode("/var/lib/morphocm", "755")),
+		executor.WriteFile(m.unitFile(server)),
+		executor.DaemonReload("morphocm.service"),
+		executor.EnableNow("morphocm.service"),
+	)
 }
 
-func (m Model) getInstallCommands(server internal.Server) []string {
-	return []string{
-		gitCloneCommand(server, "TRUECOMMERCEDK/morphocm"),
-		"cd /opt/morphocm && make build",
-		"mkdir -p /etc/morphocm",
-		"mkdir -p /var/lib/morphocm",
-		"chmod 755 /var/lib/morphocm",
-		"CUSTOM: CreateUnitFile",
-		"systemctl daemon-reload",
-		"systemctl enable --now morphocm.service",
+func (m Model) RollbackSteps(server internal.Server) []executor.Step {
+	return append(executor.RollbackSource(source),
+		executor.WriteFile(m.unitFile(server)),
+		executor.DaemonReload("morphocm.service"),
+		executor.Cmd("systemctl restart morphocm.service"),
+	)
+}
+
+func (m Model) UninstallSteps(server internal.Server, purge bool) []executor.Step {
+	steps := append(executor.RemoveUnit("morphocm.service"),
+		executor.RemoveSource(source),
+	)
+	if purge {
+		steps = append(steps,
+			executor.Cmd("rm -rf /etc/morphocm /var/lib/morphocm"),
+		)
 	}
+	return steps
 }
 
-// Custom action dispatcher.
-func (m Model) checkCustomAction(action string) string {
-	if strings.HasSuffix(action, "CreateUnitFile") {
-		return m.createUnitFile()
+func (m Model) Service(server internal.Server) executor.Service {
+	return executor.Service{
+		Unit:    "morphocm.service",
+		Files:   []executor.File{m.unitFile(server)},
+		Version: executor.SourceVersion(source),
+		Port:    executor.Override(defaults, server.Params).Port,
 	}
-	return action
 }
 
-// CreateUnitFile returns a properly escaped heredoc for remote tee.
-func (m Model) createUnitFile() string {
-	return `sudo bash -c 'cat > /etc/systemd/system/morphocm.service <<EOF
-[Unit]
+func (m Model) Params() any {
+	return defaults
+}
+
+// NeedsArch is true when the binary is built locally for the host.
+func (m Model) NeedsArch(server internal.Server) bool {
+	return server.BuildLocal
+}
+
+// source returns the repository at the configured ref.
+func (m Model) source(server internal.Server) executor.Source {
+	src := source
+	src.Ref = executor.Override(defaults, server.Params).Ref
+	return src
+}
+
+// unitFile returns the systemd unit.
+func (m Model) unitFile(server internal.Server) executor.File {
+	p := executor.Override(defaults, server.Params)
+
+	return executor.File{
+		Path: "/etc/systemd/system/morphocm.service",
+		Content: executor.Render(`[Unit]
 Description=Morpho CM Change Management Service
 After=network.target
 StartLimitIntervalSec=0
@@ -97,18 +110,10 @@ Restart=always
 RestartSec=1
 User=root
 WorkingDirectory=/opt/morphocm
-ExecStart=/opt/morphocm/morphocm --port=8089 --db-path=/var/lib/morphocm/morphocm.db
+ExecStart=/opt/morphocm/morphocm --port={{.Port}} --db-path=/var/lib/morphocm/morphocm.db
 
 [Install]
 WantedBy=multi-user.target
-EOF'`
-}
-
-func gitCloneCommand(s internal.Server, repo string) string {
-	return fmt.Sprintf(
-		"cd /opt && git clone https://%s:%s@github.com/%s.git",
-		s.GHUser,
-		s.GHPass,
-		repo,
-	)
+`, p),
+	}
 }
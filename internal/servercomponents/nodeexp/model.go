@@ -1,86 +1,97 @@
 package nodeexp
 
 import (
-	"fmt"
-	"log"
-	"strings"
-	"time"
-
 	"github.com/elsgaard/firstmate/internal"
-	"github.com/sfreiberg/simplessh"
+	"github.com/elsgaard/firstmate/internal/servercomponents/executor"
 )
 
 type Model struct{}
 
-func (m Model) Deploy(server internal.Server) error {
-	log.Printf("▶ Starting Node Exporter deploy on %s", server.FQDN)
-	return m.executeRemoteCommands(server, m.getInstallCommands())
+// Params select the release and the settings rendered into the unit file.
+type Params struct {
+	Version string `param:"version"`
+	SHA256  string `param:"sha256"` // pins the archive checksum instead of trusting sha256sums.txt
+	Port    int    `param:"port"`
 }
 
-func (m Model) Update(server internal.Server) error {
-	log.Printf("▶ Starting Node Exporter update on %s", server.FQDN)
-	return m.executeRemoteCommands(server, m.getUpdateCommands())
+// defaults apply to anything not overridden with --set or the inventory.
+var defaults = Params{
+	Version: "1.10.2",
+	Port:    9182,
 }
 
-// executeRemoteCommands centralizes connection, execution, and error handling.
-func (m Model) executeRemoteCommands(server internal.Server, cmds []string) error {
-	client, err := simplessh.ConnectWithPassword(server.FQDN, server.User, server.Pass)
-	if err != nil {
-		return fmt.Errorf("SSH connection failed: %w", err)
-	}
-	defer client.Close()
-
-	for _, cmd := range cmds {
-		serverCmd := m.checkCustomAction(cmd)
+func (m Model) UpdateSteps(server internal.Server) []executor.Step {
+	return append(executor.InstallRelease(m.release(server)),
+		executor.WriteFile(m.unitFile(server)),
+		executor.DaemonReload("node_exporter.service"),
+		executor.Cmd("systemctl restart node_exporter"),
+	)
+}
 
-		log.Printf("→ Executing: %s", serverCmd)
-		out, err := client.Exec(serverCmd)
-		if err != nil {
-			log.Printf("⚠️ Command failed: %v", err)
-			continue // `return err` to fail-fast, for now just continue
-		}
-		if len(out) > 0 {
-			log.Printf("→ Output: %s", strings.TrimSpace(string(out)))
-		}
+func (m Model) InstallSteps(server internal.Server) []executor.Step {
+	return append(executor.InstallRelease(m.release(server)),
+		executor.WriteFile(m.unitFile(server)),
+		executor.DaemonReload("node_exporter.service"),
+		executor.EnableNow("node_exporter.service"),
+	)
+}
 
-		time.Sleep(500 * time.Millisecond) // gentle pacing between commands
-	}
+func (m Model) RollbackSteps(server internal.Server) []executor.Step {
+	return append(executor.RollbackRelease(m.release(server)),
+		executor.WriteFile(m.unitFile(server)),
+		executor.DaemonReload("node_exporter.service"),
+		executor.Cmd("systemctl restart node_exporter"),
+	)
+}
 
-	log.Println("✅ Node Exporter operation completed successfully")
-	return nil
+func (m Model) UninstallSteps(server internal.Server, purge bool) []executor.Step {
+	return append(executor.RemoveUnit("node_exporter.service"),
+		executor.RemoveRelease(m.release(server)),
+	)
 }
 
-func (m Model) getUpdateCommands() []string {
-	return []string{
-		"CUSTOM: CreateUnitFile",
-		"systemctl daemon-reload",
-		"systemctl restart node_exporter",
+func (m Model) Service(server internal.Server) executor.Service {
+	return executor.Service{
+		Unit:    "node_exporter.service",
+		Files:   []executor.File{m.unitFile(server)},
+		Version: "/usr/local/bin/node_exporter --version 2>&1",
+		Port:    executor.Override(defaults, server.Params).Port,
+		Health:  "/metrics",
 	}
 }
 
-func (m Model) getInstallCommands() []string {
-	return []string{
-		"wget -q https://github.com/prometheus/node_exporter/releases/download/v1.10.2/node_exporter-1.10.2.linux-amd64.tar.gz",
-		"tar -xvf node_exporter-1.10.2.linux-amd64.tar.gz",
-		"cd node_exporter-1.10.2.linux-amd64 && mv node_exporter /usr/local/bin/",
-		"CUSTOM: CreateUnitFile",
-		"systemctl daemon-reload",
-		"systemctl enable --now node_exporter.service",
-	}
+func (m Model) Params() any {
+	return defaults
+}
+
+// NeedsArch is true: the release archive is built per architecture.
+func (m Model) NeedsArch(server internal.Server) bool {
+	return true
 }
 
-// Custom action dispatcher.
-func (m Model) checkCustomAction(action string) string {
-	if strings.HasSuffix(action, "CreateUnitFile") {
-		return m.createUnitFile()
+// release returns the upstream tarball for the configured version and the
+// host's architecture.
+func (m Model) release(server internal.Server) executor.Release {
+	p := executor.Override(defaults, server.Params)
+
+	return executor.Release{
+		Repo:     "prometheus/node_exporter",
+		Name:     "node_exporter",
+		Version:  p.Version,
+		Arch:     server.Arch,
+		Push:     server.Push,
+		SHA256:   p.SHA256,
+		Binaries: []string{"node_exporter"},
 	}
-	return action
 }
 
-// CreateUnitFile returns a properly escaped heredoc for remote tee.
-func (m Model) createUnitFile() string {
-	return `sudo bash -c 'cat > /etc/systemd/system/node_exporter.service <<EOF
-[Unit]
+// unitFile returns the systemd unit.
+func (m Model) unitFile(server internal.Server) executor.File {
+	p := executor.Override(defaults, server.Params)
+
+	return executor.File{
+		Path: "/etc/systemd/system/node_exporter.service",
+		Content: executor.Render(`[Unit]
 Description=Node Exporter
 Wants=network-online.target
 After=network-online.target
@@ -90,8 +101,9 @@ StartLimitBurst=5
 Type=simple
 Restart=on-failure
 RestartSec=5s
-ExecStart=/usr/local/bin/node_exporter --collector.logind --collector.systemd --web.listen-address=:9182
+ExecStart=/usr/local/bin/node_exporter --collector.logind --collector.systemd --web.listen-address=:{{.Port}}
 [Install]
 WantedBy=multi-user.target
-EOF'`
+`, p),
+	}
 }
@@ -1,93 +1,118 @@
 package prometheus
 
 import (
-	"fmt"
-	"log"
-	"strings"
-	"time"
-
 	"github.com/elsgaard/firstmate/internal"
-	"github.com/sfreiberg/simplessh"
+	"github.com/elsgaard/firstmate/internal/servercomponents/executor"
 )
 
 type Model struct{}
 
-func (m Model) Deploy(server internal.Server) error {
-	log.Printf("▶ Starting prometheus deploy on %s", server.FQDN)
-	return m.executeRemoteCommands(server, m.getInstallCommands())
+// Params select the release and the settings rendered into the unit and
+// configuration files.
+type Params struct {
+	Version        string `param:"version"`
+	SHA256         string `param:"sha256"` // pins the archive checksum instead of trusting sha256sums.txt
+	ExternalURL    string `param:"external_url"`
+	Retention      string `param:"retention"`
+	ScrapeInterval string `param:"scrape_interval"`
+	Alertmanager   string `param:"alertmanager"`
 }
 
-func (m Model) Update(server internal.Server) error {
-	log.Printf("▶ Starting prometheus update on %s", server.FQDN)
-	return m.executeRemoteCommands(server, m.getUpdateCommands())
+// defaults apply to anything not overridden with --set or the inventory.
+var defaults = Params{
+	Version:        "3.5.0",
+	ExternalURL:    "https://prometheus.b2bi.dk",
+	Retention:      "90d",
+	ScrapeInterval: "60s",
+	Alertmanager:   "localhost:9093",
 }
 
-// executeRemoteCommands centralizes connection, execution, and error handling.
-func (m Model) executeRemoteCommands(server internal.Server, cmds []string) error {
-	client, err := simplessh.ConnectWithPassword(server.FQDN, server.User, server.Pass)
-	if err != nil {
-		return fmt.Errorf("SSH connection failed: %w", err)
-	}
-	defer client.Close()
-
-	for _, cmd := range cmds {
-		serverCmd := m.checkCustomAction(cmd)
-
-		log.Printf("→ Executing: %s", serverCmd)
-		out, err := client.Exec(serverCmd)
-		if err != nil {
-			log.Printf("⚠️ Command failed: %v", err)
-			continue // `return err` to fail-fast, for now just continue
-		}
-		if len(out) > 0 {
-			log.Printf("→ Output: %s", strings.TrimSpace(string(out)))
-		}
-
-		time.Sleep(500 * time.Millisecond) // gentle pacing between commands
-	}
+func (m Model) UpdateSteps(server internal.Server) []executor.Step {
+	return append(executor.InstallRelease(m.release(server)),
+		executor.WriteFile(m.configFile(server)),
+		executor.WriteFile(m.unitFile(server)),
+		executor.DaemonReload("prometheus.service"),
+		executor.Cmd("systemctl restart prometheus"),
+	)
+}
 
-	log.Println("✅ prometheus operation completed successfully")
-	return nil
+func (m Model) InstallSteps(server internal.Server) []executor.Step {
+	return append(executor.InstallRelease(m.release(server)),
+		executor.Cmd("useradd -M -r -s /bin/false prometheus").Unless(executor.UserExists("prometheus")),
+		executor.Cmd("mkdir -p /etc/prometheus").Unless(executor.DirExists("/etc/prometheus")),
+		executor.WriteFile(m.configFile(server)),
+		executor.Cmd("mkdir -p /data/prometheus").Unless(executor.DirExists("/data/prometheus")),
+		executor.Cmd("chown -R prometheus:prometheus /data/prometheus /etc/prometheus").Unless(executor.OwnedBy("prometheus", "/data/prometheus", "/etc/prometheus")),
+		executor.WriteFile(m.unitFile(server)),
+		executor.DaemonReload("prometheus.service"),
+		executor.EnableNow("prometheus.service"),
+	)
 }
 
-func (m Model) getUpdateCommands() []string {
-	return []string{
-		"systemctl daemon-reload",
-		"systemctl restart prometheus",
-	}
+func (m Model) RollbackSteps(server internal.Server) []executor.Step {
+	return append(executor.RollbackRelease(m.release(server)),
+		executor.WriteFile(m.configFile(server)),
+		executor.WriteFile(m.unitFile(server)),
+		executor.DaemonReload("prometheus.service"),
+		executor.Cmd("systemctl restart prometheus"),
+	)
 }
 
-func (m Model) getInstallCommands() []string {
-	return []string{
-		"wget -q https://github.com/prometheus/prometheus/releases/download/v3.5.0/prometheus-3.5.0.linux-amd64.tar.gz",
-		"tar -xvzf prometheus-3.5.0.linux-amd64.tar.gz",
-		"cd prometheus-3.5.0.linux-amd64 && mv prometheus promtool /usr/local/bin/",
-		"mkdir -p /etc/prometheus",
-		"CUSTOM: CreateConfigFile",
-		"mkdir -p /data/prometheus",
-		"useradd -M -r -s /bin/false prometheus",
-		"chown -R prometheus:prometheus /data/prometheus /etc/prometheus",
-		"CUSTOM: CreateUnitFile",
-		"systemctl daemon-reload",
-		"systemctl enable --now prometheus",
+func (m Model) UninstallSteps(server internal.Server, purge bool) []executor.Step {
+	steps := append(executor.RemoveUnit("prometheus.service"),
+		executor.RemoveRelease(m.release(server)),
+	)
+	if purge {
+		steps = append(steps,
+			executor.Cmd("rm -rf /etc/prometheus /data/prometheus"),
+			executor.Cmd("userdel prometheus").IgnoreErrors(),
+		)
 	}
+	return steps
 }
 
-// Custom action dispatcher.
-func (m Model) checkCustomAction(action string) string {
-	if strings.HasSuffix(action, "CreateUnitFile") {
-		return m.createUnitFile()
+func (m Model) Service(server internal.Server) executor.Service {
+	return executor.Service{
+		Unit:    "prometheus.service",
+		Files:   []executor.File{m.unitFile(server), m.configFile(server)},
+		Version: "/usr/local/bin/prometheus --version 2>&1",
+		Port:    9090,
+		Health:  "/-/ready",
 	}
-	if strings.HasSuffix(action, "CreateConfigFile") {
-		return m.createConfigFile()
+}
+
+func (m Model) Params() any {
+	return defaults
+}
+
+// NeedsArch is true: the release archive is built per architecture.
+func (m Model) NeedsArch(server internal.Server) bool {
+	return true
+}
+
+// release returns the upstream tarball for the configured version and the
+// host's architecture.
+func (m Model) release(server internal.Server) executor.Release {
+	p := executor.Override(defaults, server.Params)
+
+	return executor.Release{
+		Repo:     "prometheus/prometheus",
+		Name:     "prometheus",
+		Version:  p.Version,
+		Arch:     server.Arch,
+		Push:     server.Push,
+		SHA256:   p.SHA256,
+		Binaries: []string{"prometheus", "promtool"},
 	}
-	return action
 }
 
-// CreateUnitFile returns a properly escaped heredoc for remote tee.
-func (m Model) createUnitFile() string {
-	return `sudo bash -c 'cat > /etc/systemd/system/prometheus.service <<EOF
-[Unit]
+// unitFile returns the systemd unit.
+func (m Model) unitFile(server internal.Server) executor.File {
+	p := executor.Override(defaults, server.Params)
+
+	return executor.File{
+		Path: "/etc/systemd/system/prometheus.service",
+		Content: executor.Render(`[Unit]
 Description=Prometheus TSDB
 Wants=network-online.target
 After=network-online.target
@@ -99,29 +124,34 @@ Type=simple
 ExecStart=/usr/local/bin/prometheus \
 --config.file=/etc/prometheus/prometheus.yml \
 --storage.tsdb.path=/data/prometheus \
---web.external-url=https://prometheus.b2bi.dk \
---storage.tsdb.retention.time=90d \
+--web.external-url={{.ExternalURL}} \
+--storage.tsdb.retention.time={{.Retention}} \
 --web.enable-lifecycle
 
 Restart=on-failure
 
 [Install]
 WantedBy=multi-user.target
-EOF'`
+`, p),
+	}
 }
 
-// CreateConfigFile returns a properly escaped heredoc for remote cat.
-func (m Model) createConfigFile() string {
-	return `sudo bash -c 'cat > /etc/prometheus/prometheus.yml <<EOF
-global:
-  scrape_interval: 60s
-  evaluation_interval: 60s
+// configFile returns the main configuration file.
+func (m Model) configFile(server internal.Server) executor.File {
+	p := executor.Override(defaults, server.Params)
+
+	return executor.File{
+		Path:  "/etc/prometheus/prometheus.yml",
+		Owner: "prometheus",
+		Content: executor.Render(`global:
+  scrape_interval: {{.ScrapeInterval}}
+  evaluation_interval: {{.ScrapeInterval}}
 
 alerting:
   alertmanagers:
     - static_configs:
         - targets:
-           - localhost:9093
+           - {{.Alertmanager}}
 
 rule_files:
 
@@ -131,5 +161,6 @@ scrape_configs:
       - targets: ["localhost:9090"]
         labels:
           app: "prometheus"
-EOF'`
+`, p),
+	}
 }
@@ -1,90 +1,98 @@
 package sftrip
 
 import (
-	"fmt"
-	"log"
-	"strings"
-	"time"
-
 	"github.com/elsgaard/firstmate/internal"
-	"github.com/sfreiberg/simplessh"
+	"github.com/elsgaard/firstmate/internal/servercomponents/executor"
 )
 
 type Model struct{}
 
-func (m Model) Deploy(server internal.Server) error {
-	log.Printf("▶ Starting sftrip deploy on %s", server.FQDN)
-	return m.executeRemoteCommands(server, m.getInstallCommands(server))
+// source is the repository the component is built from.
+var source = executor.Source{
+	Repo:   "TRUECOMMERCEDK/sftrip",
+	Binary: "sftrip",
 }
 
-func (m Model) Update(server internal.Server) error {
-	log.Printf("▶ Starting sftrip update on %s", server.FQDN)
-	return m.executeRemoteCommands(server, m.getUpdateCommands())
+// Params select the git ref and the settings rendered into the unit file.
+type Params struct {
+	Ref           string `param:"ref"`
+	EtcdEndpoints string `param:"etcd_endpoints"`
 }
 
-// executeRemoteCommands centralizes connection, execution, and error handling.
-func (m Model) executeRemoteCommands(server internal.Server, cmds []string) error {
-	client, err := simplessh.ConnectWithPassword(server.FQDN, server.User, server.Pass)
-	if err != nil {
-		return fmt.Errorf("SSH connection failed: %w", err)
-	}
-	defer client.Close()
-
-	for _, cmd := range cmds {
-		serverCmd := m.checkCustomAction(cmd)
-
-		log.Printf("→ Executing: %s", serverCmd)
-		out, err := client.Exec(serverCmd)
-		if err != nil {
-			log.Printf("⚠️ Command failed: %v", err)
-			continue // `return err` to fail-fast, for now just continue
-		}
-		if len(out) > 0 {
-			log.Printf("→ Output: %s", strings.TrimSpace(string(out)))
-		}
-
-		time.Sleep(500 * time.Millisecond) // gentle pacing between commands
-	}
+// defaults apply to anything not overridden with --set or the inventory.
+var defaults = Params{
+	Ref:           "main",
+	EtcdEndpoints: "http://10.15.91.217:2379,http://10.15.91.231:2379,http://10.15.91.224:2379",
+}
 
-	log.Println("✅ sftrip operation completed successfully")
-	return nil
+func (m Model) UpdateSteps(server internal.Server) []executor.Step {
+	return append(executor.UpdateSource(server, m.source(server), "--all", "--tags"),
+		executor.WriteFile(m.unitFile(server)),
+		executor.DaemonReload("sftrip.service"),
+		executor.Cmd("systemctl restart sftrip.service"),
+	)
 }
 
-func (m Model) getUpdateCommands() []string {
-	return []string{
-		"systemctl stop sftrip.service",
-		"git -C /opt/sftrip fetch --all --tags",
-		"git -C /opt/sftrip reset --hard origin/main",
-		"cd /opt/sftrip && make build",
-		"CUSTOM: CreateUnitFile",
-		"systemctl daemon-reload",
-		"systemctl restart sftrip.service",
-	}
+func (m Model) InstallSteps(server internal.Server) []executor.Step {
+	return append(executor.InstallSource(server, m.source(server)),
+		executor.Cmd("mkdir -p /etc/sftrip").Unless(executor.DirExists("/etc/sftrip")),
+		executor.WriteFile(m.unitFile(server)),
+		executor.DaemonReload("sftrip.service"),
+		executor.EnableNow("sftrip.service"),
+	)
 }
 
-func (m Model) getInstallCommands(server internal.Server) []string {
-	return []string{
-		gitCloneCommand(server, "TRUECOMMERCEDK/sftrip"),
-		"cd /opt/sftrip && make build",
-		"mkdir -p /etc/sftrip",
-		"CUSTOM: CreateUnitFile",
-		"systemctl daemon-reload",
-		"systemctl enable --now sftrip.service",
+func (m Model) RollbackSteps(server internal.Server) []executor.Step {
+	return append(executor.RollbackSource(source),
+		executor.WriteFile(m.unitFile(server)),
+		executor.DaemonReload("sftrip.service"),
+		executor.Cmd("systemctl restart sftrip.service"),
+	)
+}
+
+func (m Model) UninstallSteps(server internal.Server, purge bool) []executor.Step {
+	steps := append(executor.RemoveUnit("sftrip.service"),
+		executor.RemoveSource(source),
+	)
+	if purge {
+		steps = append(steps,
+			executor.Cmd("rm -rf /etc/sftrip"),
+		)
 	}
+	return steps
 }
 
-// Custom action dispatcher.
-func (m Model) checkCustomAction(action string) string {
-	if strings.HasSuffix(action, "CreateUnitFile") {
-		return m.createUnitFile()
+func (m Model) Service(server internal.Server) executor.Service {
+	return executor.Service{
+		Unit:    "sftrip.service",
+		Files:   []executor.File{m.unitFile(server)},
+		Version: executor.SourceVersion(source),
 	}
-	return action
 }
 
-// CreateUnitFile returns a properly escaped heredoc for remote tee.
-func (m Model) createUnitFile() string {
-	return `sudo bash -c 'cat > /etc/systemd/system/sftrip.service <<EOF
-[Unit]
+func (m Model) Params() any {
+	return defaults
+}
+
+// NeedsArch is true when the binary is built locally for the host.
+func (m Model) NeedsArch(server internal.Server) bool {
+	return server.BuildLocal
+}
+
+// source returns the repository at the configured ref.
+func (m Model) source(server internal.Server) executor.Source {
+	src := source
+	src.Ref = executor.Override(defaults, server.Params).Ref
+	return src
+}
+
+// unitFile returns the systemd unit.
+func (m Model) unitFile(server internal.Server) executor.File {
+	p := executor.Override(defaults, server.Params)
+
+	return executor.File{
+		Path: "/etc/systemd/system/sftrip.service",
+		Content: executor.Render(`[Unit]
 Description=SFTrip Service
 After=network.target
 StartLimitIntervalSec=0
@@ -95,18 +103,10 @@ Restart=always
 RestartSec=1
 User=root
 WorkingDirectory=/opt/sftrip
-ExecStart=/opt/sftrip/sftrip --config=/etc/sftrip/sftrip.json --insecure-skip-hostkey=true --etcd-endpoints "http://10.15.91.217:2379,http://10.15.91.231:2379,http://10.15.91.224:2379"
+ExecStart=/opt/sftrip/sftrip --config=/etc/sftrip/sftrip.json --insecure-skip-hostkey=true --etcd-endpoints "{{.EtcdEndpoints}}"
 
 [Install]
 WantedBy=multi-user.target
-EOF'`
-}
-
-func gitCloneCommand(s internal.Server, repo string) string {
-	return fmt.Sprintf(
-		"cd /opt && git clone https://%s:%s@github.com/%s.git",
-		s.GHUser,
-		s.GHPass,
-		repo,
-	)
+`, p),
+	}
 }
@@ -1,92 +1,107 @@
 package ubuntu
 
 import (
-	"fmt"
-	"log"
 	"strings"
-	"time"
 
 	"github.com/elsgaard/firstmate/internal"
-	"github.com/sfreiberg/simplessh"
+	"github.com/elsgaard/firstmate/internal/servercomponents/executor"
 )
 
 type Model struct{}
 
-func (m Model) Deploy(server internal.Server) error {
-	log.Printf("▶ Starting Ubuntu deploy on %s", server.FQDN)
-	return m.executeRemoteCommands(server, m.getInstallCommands())
+// Params are the NTP servers and timezone the baseline configures.
+type Params struct {
+	NTPServers string `param:"ntp_servers"`
+	Timezone   string `param:"timezone"`
 }
 
-func (m Model) Update(server internal.Server) error {
-	log.Printf("▶ Starting Ubuntu update on %s", server.FQDN)
-	return m.executeRemoteCommands(server, m.getUpdateCommands())
+// defaults apply to anything not overridden with --set or the inventory.
+var defaults = Params{
+	NTPServers: "10.16.70.11 10.16.70.12 10.16.70.13 10.16.70.14",
+	Timezone:   "Europe/Copenhagen",
 }
 
-// executeRemoteCommands centralizes connection, execution, and error handling.
-func (m Model) executeRemoteCommands(server internal.Server, cmds []string) error {
-	client, err := simplessh.ConnectWithPassword(server.FQDN, server.User, server.Pass)
-	if err != nil {
-		return fmt.Errorf("SSH connection failed: %w", err)
+// upgraded succeeds when apt has no package upgrades pending.
+const upgraded = "! apt-get -s upgrade | grep -q '^Inst '"
+
+func (m Model) UpdateSteps(server internal.Server) []executor.Step {
+	return []executor.Step{
+		executor.Cmd("apt-get update -y").WithRetry(3).Observe(),
+		executor.Cmd("apt-get upgrade -y").WithRetry(3).Unless(upgraded),
 	}
-	defer client.Close()
-
-	for _, cmd := range cmds {
-		serverCmd := m.checkCustomAction(cmd)
-
-		log.Printf("→ Executing: %s", serverCmd)
-		out, err := client.Exec(serverCmd)
-		if err != nil {
-			log.Printf("⚠️ Command failed: %v", err)
-			continue // `return err` to fail-fast, for now just continue
-		}
-		if len(out) > 0 {
-			log.Printf("→ Output: %s", strings.TrimSpace(string(out)))
-		}
-
-		time.Sleep(500 * time.Millisecond) // gentle pacing between commands
+}
+
+func (m Model) InstallSteps(server internal.Server) []executor.Step {
+	const resolvConf = "/run/systemd/resolve/resolv.conf"
+	p := executor.Override(defaults, server.Params)
+
+	// Source components are compiled on the host unless they are built
+	// locally and shipped as binaries.
+	packages := []string{"build-essential", "golang-go", "sqlite3"}
+	if server.BuildLocal {
+		packages = []string{"sqlite3"}
 	}
 
-	log.Println("✅ Ubuntu operation completed successfully")
+	return []executor.Step{
+		executor.Cmd("apt-get update -y").WithRetry(3).Observe(),
+		executor.Cmd("apt-get upgrade -y").WithRetry(3).Unless(upgraded),
+		executor.Cmd("apt-get install " + strings.Join(packages, " ") + " -y").
+			Unless(executor.PackagesInstalled(packages...)),
+		executor.Cmd("git config --global --unset-all credential.helper").
+			Unless("! git config --global --get-all credential.helper"),
+		executor.Cmd("rm -f ~/.git-credentials").Unless("! " + executor.PathExists("~/.git-credentials")),
+		executor.Cmd("mkdir -p /etc/systemd/timesyncd.conf.d").Unless(executor.DirExists("/etc/systemd/timesyncd.conf.d")),
+		executor.WriteFile(m.ntpFile(server)),
+		executor.Cmd("timedatectl set-timezone " + p.Timezone).
+			Unless(`[ "$(timedatectl show -p Timezone --value)" = ` + p.Timezone + ` ]`),
+		executor.Cmd("systemctl restart systemd-timesyncd").
+			Unless("systemctl is-active --quiet systemd-timesyncd && " +
+				`[ "$(timedatectl show-timesync -p SystemNTPServers --value)" = '` + strings.Join(strings.Fields(p.NTPServers), " ") + `' ]`),
+		executor.Cmd("timedatectl status").IgnoreErrors().Observe(),
+		executor.Cmd("timedatectl show-timesync --all").IgnoreErrors().Observe(),
+		executor.Cmd("systemctl mask --now fwupd.service").IgnoreErrors().Unless(executor.UnitMasked("fwupd.service")),
+		executor.Cmd("systemctl mask --now fwupd-refresh.service").IgnoreErrors().Unless(executor.UnitMasked("fwupd-refresh.service")),
+		executor.Cmd("systemctl mask --now fwupd-refresh.timer").IgnoreErrors().Unless(executor.UnitMasked("fwupd-refresh.timer")),
+		executor.Cmd("rm -f /etc/resolv.conf").Unless(executor.SymlinkTo("/etc/resolv.conf", resolvConf)),
+		executor.Cmd("ln -s " + resolvConf + " /etc/resolv.conf").Unless(executor.SymlinkTo("/etc/resolv.conf", resolvConf)),
+	}
+}
+
+// RollbackSteps is empty: the baseline is not versioned.
+func (m Model) RollbackSteps(server internal.Server) []executor.Step {
 	return nil
 }
 
-func (m Model) getUpdateCommands() []string {
-	return []string{
-		"apt-get update -y && apt-get upgrade -y",
-	}
+// UninstallSteps is empty: the baseline holds host-wide settings that the
+// other components and the host itself depend on.
+func (m Model) UninstallSteps(server internal.Server, purge bool) []executor.Step {
+	return nil
 }
 
-func (m Model) getInstallCommands() []string {
-	return []string{
-		"apt-get update -y && apt upgrade -y",
-		"apt-get install build-essential golang-go sqlite3 -y",
-		"git config --global credential.helper store",
-		"mkdir -p /etc/systemd/timesyncd.conf.d",
-		"CUSTOM: CreateNTPFile",
-		"timedatectl set-timezone Europe/Copenhagen",
-		"systemctl restart systemd-timesyncd",
-		"timedatectl status",
-		"timedatectl show-timesync --all",
-		"systemctl mask --now fwupd.service",
-		"systemctl mask --now fwupd-refresh.service",
-		"systemctl mask --now fwupd-refresh.timer",
-		"rm -f /etc/resolv.conf",
-		"ln -s /run/systemd/resolve/resolv.conf /etc/resolv.conf",
+func (m Model) Service(server internal.Server) executor.Service {
+	return executor.Service{
+		Files:   []executor.File{m.ntpFile(server)},
+		Version: "lsb_release -ds",
 	}
 }
 
-// Custom action dispatcher.
-func (m Model) checkCustomAction(action string) string {
-	if strings.HasSuffix(action, "CreateNTPFile") {
-		return m.createNTPFile()
-	}
-	return action
+func (m Model) Params() any {
+	return defaults
 }
 
-// CreateUnitFile returns a properly escaped heredoc for remote tee.
-func (m Model) createNTPFile() string {
-	return `sudo bash -c 'cat > /etc/systemd/timesyncd.conf.d/custom.conf <<EOF
-[Time]
-NTP=10.16.70.11 10.16.70.12 10.16.70.13 10.16.70.14
-EOF'`
+// NeedsArch is false: nothing is built or downloaded per architecture.
+func (m Model) NeedsArch(server internal.Server) bool {
+	return false
+}
+
+// ntpFile returns the timesyncd drop-in with the NTP servers.
+func (m Model) ntpFile(server internal.Server) executor.File {
+	p := executor.Override(defaults, server.Params)
+
+	return executor.File{
+		Path: "/etc/systemd/timesyncd.conf.d/custom.conf",
+		Content: executor.Render(`[Time]
+NTP={{.NTPServers}}
+`, p),
+	}
 }